@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+)
+
+// If not empty, admin API requests need an "Authorization: Bearer <token>" header. Without a token the admin API
+// is only served on loopback addresses.
+var AdminToken string
+
+type adminPushRequest struct {
+	// Empty for all registered devices.
+	Group   string      `json:"group"`
+	CodeStr string      `json:"codeStr"`
+	VoiceID *spkVoiceID `json:"voiceId"`
+}
+
+func adminAuthorized(r *http.Request) bool {
+	if AdminToken == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+AdminToken)) == 1
+}
+
+func adminWriteJson(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("admin api encode error: ", err)
+	}
+}
+
+func adminHandleRegistrations(w http.ResponseWriter, r *http.Request) {
+	if !adminAuthorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	regs := RegistryGet(r.URL.Query().Get("group"))
+	if regs == nil {
+		regs = []registration{}
+	}
+	adminWriteJson(w, regs)
+}
+
+func adminHandlePush(w http.ResponseWriter, r *http.Request) {
+	if !adminAuthorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var pr adminPushRequest
+	if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if pr.CodeStr == "" || len(pr.CodeStr)%2 != 0 {
+		http.Error(w, "invalid code str", http.StatusBadRequest)
+		return
+	}
+
+	adminWriteJson(w, map[string]int{"pushed": RegistryPush(pr.Group, pr.CodeStr, pr.VoiceID)})
+}
+
+// Returns true if addr only accepts connections from the local host.
+func adminIsLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// AdminCheckSettings returns an error if the admin API would be served on addr without authentication.
+func AdminCheckSettings(addr string) error {
+	if AdminToken == "" && !adminIsLoopback(addr) {
+		return errors.New("the admin api needs -admintoken if it's not served on a loopback address")
+	}
+	return nil
+}
+
+// AdminProcess serves the admin API on the given address.
+func AdminProcess(addr string) {
+	log.Printf("starting admin api server on %s\n", addr)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /registrations", adminHandleRegistrations)
+	mux.HandleFunc("POST /push", adminHandlePush)
+
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Println("admin api server error: ", err)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAdminCheckSettings(t *testing.T) {
+	savedToken := AdminToken
+	defer func() { AdminToken = savedToken }()
+
+	tests := []struct {
+		addr    string
+		token   string
+		wantErr bool
+	}{
+		{"127.0.0.1:8082", "", false},
+		{"[::1]:8082", "", false},
+		{"localhost:8082", "", false},
+		{":8082", "", true},
+		{"0.0.0.0:8082", "", true},
+		{"192.168.1.10:8082", "", true},
+		{"192.168.1.10:8082", "secret", false},
+		{":8082", "secret", false},
+	}
+	for _, tt := range tests {
+		AdminToken = tt.token
+		if err := AdminCheckSettings(tt.addr); (err != nil) != tt.wantErr {
+			t.Errorf("AdminCheckSettings(%q) with token %q = %v, want error %v", tt.addr, tt.token, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAdminAuthorized(t *testing.T) {
+	savedToken := AdminToken
+	defer func() { AdminToken = savedToken }()
+	AdminToken = "secret"
+
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"Bearer secret", true},
+		{"Bearer other", false},
+		{"secret", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/registrations", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		if got := adminAuthorized(r); got != tt.want {
+			t.Errorf("adminAuthorized(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
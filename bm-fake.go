@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+)
+
+// Canned data served by the fake BM API server, in the BM v2 API's format. Profiles, devices and DMR+ statuses are
+// keyed by device ID, the "*" entries are served for unknown device IDs.
+type bmFakeData struct {
+	Servers  []networkServerData
+	Profiles map[string]bmProfile
+	Devices  map[string]bmDevice
+	DMRPlus  map[string]dmrplusMasterStatus
+}
+
+// The server hosts are loopback addresses, so a Homebrew connection to 127.0.0.1 gets the BM announcement.
+var bmFakeDefaultData = bmFakeData{
+	Servers: []networkServerData{
+		{Network: "BrandMeister", Name: "BM Fake/2161", Host: "127.0.0.1"},
+		{Network: "BrandMeister", Name: "BM Fake/2162", Host: "127.0.0.2"},
+		{Network: "BrandMeister", Name: "BM Fake/2163", Host: "127.0.0.3"},
+		{Network: "BrandMeister", Name: "BM Fake/2164", Host: "127.0.0.4"},
+		{Network: "DMR+", Name: "DMR+ Fake", Host: "127.0.0.5"},
+	},
+	Profiles: map[string]bmProfile{
+		"*": {
+			StaticSubscriptions:  []bmSubscription{{Talkgroup: "216", Slot: 1}, {Talkgroup: "91", Slot: 2}},
+			DynamicSubscriptions: []bmSubscription{{Talkgroup: "2161", Slot: 2}},
+		},
+	},
+	Devices: map[string]bmDevice{
+		"*": {LastHeardTalkgroup: "2161"},
+	},
+	DMRPlus: map[string]dmrplusMasterStatus{
+		"*": {Reflector: "4012", Talkgroup: "262"},
+	},
+}
+
+// BMFakeLoadData loads the canned data from a JSON file in the bmFakeData format.
+func BMFakeLoadData(path string) (bmFakeData, error) {
+	var data bmFakeData
+
+	f, err := os.Open(path)
+	if err != nil {
+		return data, err
+	}
+	defer f.Close()
+
+	err = json.NewDecoder(f).Decode(&data)
+	return data, err
+}
+
+func bmFakeWriteJson(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("fake bm api encode error: ", err)
+	}
+}
+
+// Writes the entry for the request's device ID, or the "*" entry for unknown device IDs.
+func bmFakeWriteEntry(w http.ResponseWriter, r *http.Request, lookup func(id string) (interface{}, bool)) {
+	v, ok := lookup(r.PathValue("id"))
+	if !ok {
+		v, ok = lookup("*")
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	bmFakeWriteJson(w, v)
+}
+
+// Returns the handler of the fake BM API, DMR+ status API and server list. The BM API base URL is /v2, the DMR+
+// status API URL is /dmrplus/{id}, the server list URL is /servers.json.
+func bmFakeHandler(data bmFakeData) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /servers.json", func(w http.ResponseWriter, r *http.Request) {
+		bmFakeWriteJson(w, data.Servers)
+	})
+	mux.HandleFunc("GET /v2/device/{id}/profile", func(w http.ResponseWriter, r *http.Request) {
+		bmFakeWriteEntry(w, r, func(id string) (interface{}, bool) { v, ok := data.Profiles[id]; return v, ok })
+	})
+	mux.HandleFunc("GET /v2/device/{id}", func(w http.ResponseWriter, r *http.Request) {
+		bmFakeWriteEntry(w, r, func(id string) (interface{}, bool) { v, ok := data.Devices[id]; return v, ok })
+	})
+	mux.HandleFunc("GET /dmrplus/{id}", func(w http.ResponseWriter, r *http.Request) {
+		bmFakeWriteEntry(w, r, func(id string) (interface{}, bool) { v, ok := data.DMRPlus[id]; return v, ok })
+	})
+	return mux
+}
+
+// BMFakeStart starts serving the fake BM API, DMR+ status API and server list on the given address, and returns
+// the address it listens on.
+func BMFakeStart(addr string, data bmFakeData) (string, error) {
+	// Listening before returning, so the server is up when the first server list update starts.
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("starting fake bm api server on %s\n", listener.Addr().String())
+	go func() {
+		if err := http.Serve(listener, bmFakeHandler(data)); err != nil {
+			log.Println("fake bm api server error: ", err)
+		}
+	}()
+	return listener.Addr().String(), nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBMFakeServesV2JSON(t *testing.T) {
+	server := httptest.NewServer(bmFakeHandler(bmFakeDefaultData))
+	defer server.Close()
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/v2/device/2161234/profile", []string{`"staticSubscriptions":[{"talkgroup":216,"slot":1}`,
+			`"dynamicSubscriptions":[{"talkgroup":2161,"slot":2}]`}},
+		{"/v2/device/2161234", []string{`"lastHeardTalkgroup":2161`, `"blocked":false`}},
+		{"/dmrplus/2161234", []string{`"reflector":4012`, `"talkgroup":262`}},
+		{"/servers.json", []string{`"Name":"BM Fake/2161"`}},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		for _, want := range tt.want {
+			if !strings.Contains(string(body), want) {
+				t.Errorf("%s: %s doesn't contain %s", tt.path, body, want)
+			}
+		}
+	}
+}
+
+func TestBMFakeClientData(t *testing.T) {
+	savedBM, savedDMRPlus := BMAPIURL, DMRPlusAPIURL
+	t.Cleanup(func() { BMAPIURL, DMRPlusAPIURL = savedBM, savedDMRPlus })
+
+	data := bmFakeData{
+		Profiles: map[string]bmProfile{
+			"2161234": {
+				StaticSubscriptions:  []bmSubscription{{Talkgroup: "91", Slot: 1}},
+				DynamicSubscriptions: []bmSubscription{{Talkgroup: "4021", Slot: 2}, {Talkgroup: "4000", Slot: 1}},
+			},
+			"2161235": {DynamicSubscriptions: []bmSubscription{{Talkgroup: "2161", Slot: 2}}},
+		},
+		Devices: map[string]bmDevice{
+			"2161234": {Blocked: true, CallRouting: true, LastHeardTalkgroup: "9990"},
+		},
+		DMRPlus: map[string]dmrplusMasterStatus{
+			"2161234": {Master: "IPSC2", Reflector: "4012"},
+		},
+	}
+	addr, err := BMFakeStart("127.0.0.1:0", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	BMAPIURL = "http://" + addr + "/v2"
+	DMRPlusAPIURL = "http://" + addr + "/dmrplus/{id}"
+
+	tests := []struct {
+		name     string
+		provider NetworkStatusProvider
+		clientId uint32
+		want     networkClientData
+		wantErr  bool
+	}{
+		{"bm profile and device", &bmStatusProvider{}, 2161234, networkClientData{
+			StaticSubscriptions:    []networkSubscription{{"91", 1}},
+			ReflectorSubscriptions: []networkSubscription{{"4021", 2}},
+			Device:                 networkDeviceData{Blocked: true, CallRouting: true, LastHeardTalkgroup: "9990"},
+		}, false},
+		// The device request fails, only the profile is used.
+		{"bm profile only", &bmStatusProvider{}, 2161235, networkClientData{
+			DynamicSubscriptions: []networkSubscription{{"2161", 2}},
+		}, false},
+		{"bm unknown device", &bmStatusProvider{}, 2161236, networkClientData{}, true},
+		{"dmr+", &dmrplusStatusProvider{}, 2161234, networkClientData{
+			ServerName:             "IPSC2",
+			ReflectorSubscriptions: []networkSubscription{{Talkgroup: "4012"}},
+		}, false},
+		{"dmr+ unknown device", &dmrplusStatusProvider{}, 2161236, networkClientData{}, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.provider.FetchClientData(tt.clientId, &networkServerData{Host: "127.0.0.1"})
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, %v, want %+v, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
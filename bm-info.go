@@ -1,156 +1,90 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
-	"net"
-	"net/http"
+	"strconv"
 	"strings"
-	"sync"
-	"time"
 )
 
-type bmServerData struct {
-	Network string
-	Name    string
-	Host    string
-}
+var BMAPIURL = "https://api.brandmeister.network/v2"
 
+// A subscription in the BM v2 /device/<id>/profile response. Talkgroups are numbers.
 type bmSubscription struct {
-	Talkgroup string
+	Talkgroup networkTalkgroupID `json:"talkgroup"`
+	Slot      uint8              `json:"slot"`
 }
 
-type bmClientData struct {
-	StaticSubscriptions  []bmSubscription
-	DynamicSubscriptions []bmSubscription
+type bmProfile struct {
+	StaticSubscriptions  []bmSubscription `json:"staticSubscriptions"`
+	DynamicSubscriptions []bmSubscription `json:"dynamicSubscriptions"`
 }
 
-type bmServerIP string
+// Fields of the BM v2 /device/<id> response used in the announcement.
+type bmDevice struct {
+	Blocked            bool               `json:"blocked"`
+	CallRouting        bool               `json:"callRouting"`
+	LastHeardTalkgroup networkTalkgroupID `json:"lastHeardTalkgroup"`
+}
 
-var bmServerIPHosts = make(map[bmServerIP]bmServerData)
-var bmServerIPHostsMutex = &sync.Mutex{}
+type bmStatusProvider struct{}
 
-func getJson(url string, target interface{}) error {
-	var httpClient = &http.Client{Timeout: 2000 * time.Millisecond}
-	r, err := httpClient.Get(url)
-	if err != nil {
-		return err
-	}
-	defer r.Body.Close()
-
-	return json.NewDecoder(r.Body).Decode(target)
+func (p *bmStatusProvider) Networks() []string {
+	return []string{"BrandMeister"}
 }
 
-func BMGetClientData(clientId uint32, result *bmClientData, finished chan bool) {
-	url := fmt.Sprintf("https://api.brandmeister.network/v2/device/%d/profile", clientId)
-	err := getJson(url, result)
-	if err != nil {
-		log.Println("getjson error: ", err)
-	} else {
-		finished <- true
-	}
+// Returns true if the talkgroup is a reflector. Reflectors are linked by dynamic subscriptions to 4001-4999, and
+// TG 4000 unlinks them.
+func bmIsReflector(tg networkTalkgroupID) bool {
+	n, err := strconv.Atoi(string(tg))
+	return err == nil && n > 4000 && n <= 4999
 }
 
-func BMGenerateCodeStrFromClientData(cd *bmClientData, sd *bmServerData, shortened bool) string {
-	var networkIDStr string
-	var stgStr string
-	var dtgStr string
-
-	if lastIndex := strings.LastIndex(sd.Name, "/"); lastIndex >= 0 {
-		for i := lastIndex + 1; i < len(sd.Name); i++ {
-			networkIDStr += "0" + string(sd.Name[i])
+// Converts the BM profile and device data to client data. device is nil if it's not available.
+func bmGetClientData(profile *bmProfile, device *bmDevice) networkClientData {
+	var result networkClientData
+	for _, sub := range profile.StaticSubscriptions {
+		if sub.Talkgroup != "" {
+			result.StaticSubscriptions = append(result.StaticSubscriptions, networkSubscription{string(sub.Talkgroup), sub.Slot})
 		}
 	}
-
-	if !shortened {
-		if len(cd.StaticSubscriptions) > 0 {
-			if len(cd.StaticSubscriptions) == 1 {
-				stgStr = "LKSTTG"
-			} else {
-				stgStr = "LKSTGS"
-			}
-			for i := 0; i < len(cd.StaticSubscriptions); i++ {
-				if i > 0 {
-					stgStr += "ND"
-				}
-				tg := cd.StaticSubscriptions[i].Talkgroup
-				for j := 0; j < len(tg); j++ {
-					stgStr += "0" + string(tg[j])
-				}
-			}
+	for _, sub := range profile.DynamicSubscriptions {
+		switch {
+		// TG 4000 is used for unlinking, it's not a real dynamic subscription.
+		case sub.Talkgroup == "" || sub.Talkgroup == "4000":
+		case bmIsReflector(sub.Talkgroup):
+			result.ReflectorSubscriptions = append(result.ReflectorSubscriptions, networkSubscription{string(sub.Talkgroup), sub.Slot})
+		default:
+			result.DynamicSubscriptions = append(result.DynamicSubscriptions, networkSubscription{string(sub.Talkgroup), sub.Slot})
 		}
-
-		if len(cd.DynamicSubscriptions) > 0 {
-			if len(cd.DynamicSubscriptions) > 1 {
-				dtgStr = "LKDNGS"
-			} else if cd.DynamicSubscriptions[0].Talkgroup != "4000" {
-				dtgStr = "LKDNTG"
-			}
-			for i := 0; i < len(cd.DynamicSubscriptions); i++ {
-				if cd.DynamicSubscriptions[i].Talkgroup == "4000" {
-					continue
-				}
-
-				if i > 0 {
-					dtgStr += "ND"
-				}
-				tg := cd.DynamicSubscriptions[i].Talkgroup
-				for j := 0; j < len(tg); j++ {
-					dtgStr += "0" + string(tg[j])
-				}
-			}
+	}
+	if device != nil {
+		result.Device = networkDeviceData{
+			Blocked:            device.Blocked,
+			CallRouting:        device.CallRouting,
+			LastHeardTalkgroup: string(device.LastHeardTalkgroup),
 		}
 	}
-
-	return "BM" + networkIDStr + stgStr + dtgStr
-}
-
-func BMGetServerDataForServerIP(addr string) (bmServerData, bool) {
-	bmServerIPHostsMutex.Lock()
-	defer bmServerIPHostsMutex.Unlock()
-	val, ok := bmServerIPHosts[bmServerIP(addr)]
-	return val, ok
+	return result
 }
 
-func BMUpdateServerList() {
-	log.Println("updating bm server list")
-
-	var bmServers []bmServerData
-	err := getJson("http://x.sharkrf.com/db/homebrew/servers.json", &bmServers)
-	if err != nil {
-		log.Println("update bm server list getjson error: ", err)
-		return
-	}
-
-	newList := make(map[bmServerIP]bmServerData)
-	for _, bmServer := range bmServers {
-		if bmServer.Network != "BrandMeister" {
-			continue
-		}
-
-		addrs, err := net.LookupHost(bmServer.Host)
-		if err == nil {
-			// Storing all addresses so we can get server data for an IP later.
-			for _, addr := range addrs {
-				newList[bmServerIP(addr)] = bmServer
-			}
-		}
+func (p *bmStatusProvider) FetchClientData(clientId uint32, sd *networkServerData) (networkClientData, error) {
+	var profile bmProfile
+	url := fmt.Sprintf("%s/device/%d/profile", strings.TrimRight(BMAPIURL, "/"), clientId)
+	if err := getJson(url, &profile); err != nil {
+		return networkClientData{}, err
 	}
 
-	if len(newList) > 3 {
-		bmServerIPHostsMutex.Lock()
-		bmServerIPHosts = newList
-		bmServerIPHostsMutex.Unlock()
-		log.Println("updating bm server list finished")
-	} else {
-		log.Println("updating bm server list failed")
+	// The profile is enough for the announcement, so failing to get the device data is not an error.
+	var device bmDevice
+	url = fmt.Sprintf("%s/device/%d", strings.TrimRight(BMAPIURL, "/"), clientId)
+	if err := getJson(url, &device); err != nil {
+		log.Printf("warning: can't get bm device data for cid:%d: %v\n", clientId, err)
+		return bmGetClientData(&profile, nil), nil
 	}
+	return bmGetClientData(&profile, &device), nil
 }
 
-func BMProcess() {
-	for {
-		BMUpdateServerList()
-		time.Sleep(time.Hour)
-	}
+func (p *bmStatusProvider) GenerateNameCodeStr(hasCodePair func(codePair string) bool) string {
+	return "BM"
 }
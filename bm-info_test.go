@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestBMGetClientData(t *testing.T) {
+	// Shaped like the BM v2 /device/<id>/profile response.
+	data := `{
+		"staticSubscriptions": [{"talkgroup": 216, "slot": 1}, {"talkgroup": 91, "slot": 2}],
+		"dynamicSubscriptions": [{"talkgroup": 4000, "slot": 2}, {"talkgroup": 4021, "slot": 2}, {"talkgroup": 2161, "slot": 2}],
+		"timedSubscriptions": [],
+		"blockedGroups": []
+	}`
+
+	var profile bmProfile
+	if err := json.Unmarshal([]byte(data), &profile); err != nil {
+		t.Fatal(err)
+	}
+	want := networkClientData{
+		StaticSubscriptions:    []networkSubscription{{"216", 1}, {"91", 2}},
+		DynamicSubscriptions:   []networkSubscription{{"2161", 2}},
+		ReflectorSubscriptions: []networkSubscription{{"4021", 2}},
+	}
+	if got := bmGetClientData(&profile, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestBMIsReflector(t *testing.T) {
+	tests := map[networkTalkgroupID]bool{"4000": false, "4001": true, "4999": true, "5000": false, "91": false, "": false}
+	for tg, want := range tests {
+		if got := bmIsReflector(tg); got != want {
+			t.Errorf("bmIsReflector(%q) = %v, want %v", tg, got, want)
+		}
+	}
+}
+
+func TestBMDeviceStatusCodeStr(t *testing.T) {
+	// Shaped like the BM v2 /device/<id>/profile and /device/<id> responses.
+	profileData := `{
+		"staticSubscriptions": [{"talkgroup": 216, "slot": 1}],
+		"dynamicSubscriptions": [{"talkgroup": 4021, "slot": 2}],
+		"timedSubscriptions": [],
+		"blockedGroups": []
+	}`
+	base := "BM02010601" + "LKSTTG#2#1#6" + "LKRF#4#0#2#1"
+	noSlots := hasCodePairsExcept("TS")
+
+	tests := []struct {
+		name        string
+		device      string // Empty if the device request failed.
+		shortened   bool
+		hasCodePair func(codePair string) bool
+		want        string
+	}{
+		{"no device data", "", false, noSlots, base},
+		{"idle device", `{"id": 2161234, "callsign": "HA2NON", "blocked": false, "callRouting": false, "lastHeardTalkgroup": null}`,
+			false, noSlots, base},
+		{"blocked", `{"id": 2161234, "blocked": true}`, false, noSlots, "BM02010601BK" + base[10:]},
+		{"blocked shortened", `{"id": 2161234, "blocked": true, "callRouting": true}`, true, noSlots, "BM02010601BK"},
+		{"blocked without phrase", `{"id": 2161234, "blocked": true}`, false, hasCodePairsExcept("TS", "BK"), base},
+		{"call routing", `{"id": 2161234, "callRouting": true}`, false, noSlots, base + "RE"},
+		{"last heard", `{"id": 2161234, "lastHeardTalkgroup": 2161}`, false, noSlots, base + "LHTG#2#1#6#1"},
+		{"last heard string", `{"id": 2161234, "lastHeardTalkgroup": "91"}`, false, noSlots, base + "LHTG#9#1"},
+		{"last heard without phrase", `{"id": 2161234, "lastHeardTalkgroup": 2161}`, false,
+			hasCodePairsExcept("TS", "LH"), base},
+		{"all", `{"id": 2161234, "blocked": true, "callRouting": true, "lastHeardTalkgroup": 2161}`, false, noSlots,
+			"BM02010601BK" + base[10:] + "RE" + "LHTG#2#1#6#1"},
+	}
+	for _, tt := range tests {
+		var profile bmProfile
+		if err := json.Unmarshal([]byte(profileData), &profile); err != nil {
+			t.Fatal(err)
+		}
+		var device *bmDevice
+		if tt.device != "" {
+			device = &bmDevice{}
+			if err := json.Unmarshal([]byte(tt.device), device); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+
+		cd := bmGetClientData(&profile, device)
+		sd := networkServerData{Network: "BrandMeister", Name: "BM/2161"}
+		got := NetworkGenerateCodeStrFromClientData(&bmStatusProvider{}, &cd, &sd, tt.shortened, 0, tt.hasCodePair)
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strconv"
+	"time"
+)
+
+// Time announcements are in this time zone.
+var ClockLocation = time.Local
+
+// Returns "time is <hour> <minutes> a m/p m" for the given time in 12-hour format.
+func ClockGenerateCodeStr(t time.Time) string {
+	hour := t.Hour() % 12
+	if hour == 0 {
+		hour = 12
+	}
+
+	res := "TI" + codeStrForCount(hour)
+	switch minute := t.Minute(); {
+	case minute == 0:
+	case minute < 10:
+		// Like "nine oh five".
+		res += "TO" + codeStrForCount(minute)
+	default:
+		res += codeStrForCount(minute)
+	}
+
+	if t.Hour() < 12 {
+		return res + "TATM"
+	}
+	return res + "TPTM"
+}
+
+// Returns the time in the format of the voice pack manifest, or the default format if the manifest has none.
+func clockGenerateCodeStrForManifest(t time.Time, m *voiceManifest) string {
+	if m.Time == "" {
+		return ClockGenerateCodeStr(t)
+	}
+
+	hour := t.Hour()
+	var ampm string
+	if m.Clock != "24h" {
+		if hour = hour % 12; hour == 0 {
+			hour = 12
+		}
+		ampm = "TATM"
+		if t.Hour() >= 12 {
+			ampm = "TPTM"
+		}
+	}
+
+	vars := map[string]string{"hour": codeStrForNumber(strconv.Itoa(hour)), "ampm": ampm}
+	if t.Minute() != 0 {
+		vars["minute"] = codeStrForNumber(strconv.Itoa(t.Minute()))
+	}
+	return templateExpand(m.Time, vars)
+}
+
+// Resolves the time placeholder to the current time.
+func ClockResolvePlaceholder(req *spkRequest, hasCodePair func(codePair string) bool) (string, bool) {
+	m := VoiceGetManifest(req)
+	return clockGenerateCodeStrForManifest(time.Now().In(ClockLocation), &m), true
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockGenerateCodeStrForManifest(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2026, 10, 19, hour, minute, 0, 0, time.UTC) }
+	english := voiceManifest{}
+	german := voiceManifest{Time: "ESIS{hour}UR{minute}", Clock: "24h"}
+	twelve := voiceManifest{Time: "TI{hour}{minute}{ampm}"}
+
+	tests := []struct {
+		name     string
+		manifest voiceManifest
+		t        time.Time
+		want     string
+	}{
+		{"default morning", english, at(9, 5), "TI09TO05TATM"},
+		{"default evening", english, at(21, 30), "TI0930TPTM"},
+		{"default noon", english, at(12, 0), "TI12TPTM"},
+		{"default midnight", english, at(0, 0), "TI12TATM"},
+		{"24h", german, at(14, 5), "ESIS#1#4UR#5"},
+		{"24h on the hour", german, at(0, 0), "ESIS#0UR"},
+		{"12h template", twelve, at(13, 45), "TI#1#4#5TPTM"},
+		{"12h template midnight", twelve, at(0, 0), "TI#1#2TATM"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clockGenerateCodeStrForManifest(tt.t, &tt.manifest); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
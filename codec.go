@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// Describes how the voice frames of a codec are stored in the asset files and sent in response packets.
+type spkCodec struct {
+	Name            string
+	Dir             string // Asset subdirectory in the voice directory.
+	FrameSize       int
+	FramesPerPacket int
+	PacketType      spkPacketType
+	// Set for codecs which older firmware doesn't understand, they are used only if native frames are enabled and
+	// the request's protocol version has them.
+	Native bool
+	// Codec used if the native codec can't be used. The device rewraps the fallback frames itself. Requests are
+	// not answered if a native codec has no fallback.
+	Fallback *spkCodec
+}
+
+var spkCodecDMR = &spkCodec{
+	Name:            "ambe-dmr",
+	Dir:             "dmr",
+	FrameSize:       9,
+	FramesPerPacket: 3,
+	PacketType:      SPK_PACKET_TYPE_AMBE_RESPONSE,
+}
+
+var spkCodecDSTAR = &spkCodec{
+	Name:            "ambe-dstar",
+	Dir:             "dstar",
+	FrameSize:       9,
+	FramesPerPacket: 3,
+	PacketType:      SPK_PACKET_TYPE_AMBE_RESPONSE,
+}
+
+var spkCodecP25 = &spkCodec{
+	Name:            "imbe-p25",
+	Dir:             "p25",
+	FrameSize:       18,
+	FramesPerPacket: 3,
+	PacketType:      SPK_PACKET_TYPE_IMBE_RESPONSE,
+}
+
+// YSF V/D mode: AMBE+2 in 104 bit voice channels.
+var spkCodecYSFDN = &spkCodec{
+	Name:            "ambe-ysf-dn",
+	Dir:             "ysf-dn",
+	FrameSize:       13,
+	FramesPerPacket: 3,
+	PacketType:      SPK_PACKET_TYPE_YSF_DN_RESPONSE,
+	Native:          true,
+	Fallback:        spkCodecDMR,
+}
+
+// YSF voice FR mode: IMBE with YSF FEC. It has no fallback, as VW radios can't decode AMBE+2.
+var spkCodecYSFVW = &spkCodec{
+	Name:            "imbe-ysf-vw",
+	Dir:             "ysf-vw",
+	FrameSize:       18,
+	FramesPerPacket: 3,
+	PacketType:      SPK_PACKET_TYPE_YSF_VW_RESPONSE,
+	Native:          true,
+}
+
+var spkCodecNXDN = &spkCodec{
+	Name:            "ambe-nxdn",
+	Dir:             "nxdn",
+	FrameSize:       9,
+	FramesPerPacket: 3,
+	PacketType:      SPK_PACKET_TYPE_NXDN_RESPONSE,
+	Native:          true,
+	Fallback:        spkCodecDMR,
+}
+
+// If false, codecs with a fallback always use their fallback codec. Older firmware only understands AMBE and
+// IMBE response packets.
+var CodecNativeEnabled = false
+
+// Codec used for the C4FM modem modes, as the request doesn't tell which YSF voice mode is in use.
+var CodecYSF = spkCodecYSFDN
+
+// Sets CodecYSF by mode name ("dn" or "vw"). Returns false if the mode name is invalid.
+func CodecSetYSFMode(mode string) bool {
+	switch mode {
+	case "dn":
+		CodecYSF = spkCodecYSFDN
+	case "vw":
+		CodecYSF = spkCodecYSFVW
+	default:
+		return false
+	}
+	return true
+}
+
+// Returns nil if the modem mode is not supported.
+func getCodecForModemMode(modemMode spkModemMode) *spkCodec {
+	switch modemMode {
+	case SPK_MODEM_MODE_DMR:
+		return spkCodecDMR
+	case SPK_MODEM_MODE_C4FM, SPK_MODEM_MODE_C4FM_HALF_DEVIATION:
+		return CodecYSF
+	case SPK_MODEM_MODE_NXDN:
+		return spkCodecNXDN
+	case SPK_MODEM_MODE_DSTAR:
+		return spkCodecDSTAR
+	case SPK_MODEM_MODE_P25:
+		return spkCodecP25
+	default:
+		return nil
+	}
+}
+
+// Logs the codec settings at startup. Returns an error if the settings can't answer all modem modes.
+func CodecCheckSettings() error {
+	if !CodecNativeEnabled {
+		if CodecYSF.Fallback == nil {
+			return fmt.Errorf("%s needs native frames, use -nativecodecs", CodecYSF.Name)
+		}
+		log.Printf("native frames are disabled, using %s frames for %s and %s\n", spkCodecDMR.Name, CodecYSF.Name,
+			spkCodecNXDN.Name)
+		return nil
+	}
+
+	if CodecYSF.Fallback == nil && !codecHasAssets(CodecYSF) {
+		return fmt.Errorf("no voice has %s assets and there's no fallback codec", CodecYSF.Name)
+	}
+	log.Printf("native frames are enabled for protocol v2 and later, older firmware gets %s frames\n",
+		spkCodecDMR.Name)
+	return nil
+}
+
+// Returns true if any voice has assets for the codec.
+func codecHasAssets(codec *spkCodec) bool {
+	if assetDirHasFiles("voices/v0/" + codec.Dir) {
+		return true
+	}
+	for _, p := range voiceGetPacks() {
+		if assetDirHasFiles(voicePacksDir + p.Name + "/" + codec.Dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the codec to stream with, falling back if native frames are disabled, the protocol version has no
+// native frames, or there are no assets in the codec's directory for the voice. Returns nil if there's no usable
+// codec.
+func resolveCodec(protocol *spkProtocol, req *spkRequest, codec *spkCodec) *spkCodec {
+	for codec != nil && codec.Native {
+		// Disabled native frames are logged at startup.
+		if CodecNativeEnabled {
+			if !protocol.NativeCodecs {
+				if codec.Fallback == nil {
+					log.Printf("protocol v%d has no %s frames and there's no fallback codec\n", protocol.Version,
+						codec.Name)
+				}
+			} else if dirs := protocol.GetVoiceDirs(req, codec); len(dirs) > 0 && assetDirHasFiles(dirs[0]) {
+				break
+			} else if codec.Fallback == nil {
+				log.Printf("no %s assets for the voice and no fallback codec\n", codec.Name)
+			} else {
+				log.Printf("using %s frames for %s, no assets for the voice\n", codec.Fallback.Name, codec.Name)
+			}
+		}
+		codec = codec.Fallback
+	}
+	return codec
+}
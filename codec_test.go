@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestResolveCodec(t *testing.T) {
+	savedNative := CodecNativeEnabled
+	t.Cleanup(func() { CodecNativeEnabled = savedNative })
+
+	dir := "test-codec-voice"
+	streamTestAssets(t, dir+"/nxdn", spkCodecNXDN.FrameSize, map[string][]byte{"A1": {1}})
+	dirs := func(req *spkRequest, codec *spkCodec) []string { return []string{dir + "/" + codec.Dir + "/"} }
+	oldProtocol := &spkProtocol{Version: 1, GetVoiceDirs: dirs}
+	newProtocol := &spkProtocol{Version: 2, GetVoiceDirs: dirs, NativeCodecs: true}
+
+	tests := []struct {
+		name     string
+		native   bool
+		protocol *spkProtocol
+		codec    *spkCodec
+		want     *spkCodec
+	}{
+		{"not native", true, newProtocol, spkCodecDMR, spkCodecDMR},
+		{"native", true, newProtocol, spkCodecNXDN, spkCodecNXDN},
+		{"native disabled", false, newProtocol, spkCodecNXDN, spkCodecDMR},
+		{"old protocol", true, oldProtocol, spkCodecNXDN, spkCodecDMR},
+		{"no assets", true, newProtocol, spkCodecYSFDN, spkCodecDMR},
+		{"no assets and no fallback", true, newProtocol, spkCodecYSFVW, nil},
+		{"old protocol and no fallback", true, oldProtocol, spkCodecYSFVW, nil},
+	}
+	for _, tt := range tests {
+		CodecNativeEnabled = tt.native
+		if got := resolveCodec(tt.protocol, &spkRequest{}, tt.codec); got != tt.want {
+			t.Errorf("%s: resolveCodec() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCodecCheckSettings(t *testing.T) {
+	savedNative, savedYSF := CodecNativeEnabled, CodecYSF
+	t.Cleanup(func() { CodecNativeEnabled, CodecYSF = savedNative, savedYSF })
+	voiceTestPacks(t, "srf-male-en")
+
+	tests := []struct {
+		name    string
+		native  bool
+		mode    string
+		assets  bool
+		wantErr bool
+	}{
+		{"dn", false, "dn", false, false},
+		{"native dn without assets", true, "dn", false, false},
+		{"vw without native frames", false, "vw", true, true},
+		{"vw without assets", true, "vw", false, true},
+		{"vw", true, "vw", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.assets {
+				streamTestAssets(t, voicePacksDir+"srf-male-en/ysf-vw", spkCodecYSFVW.FrameSize,
+					map[string][]byte{"A1": {1}})
+			}
+			CodecNativeEnabled = tt.native
+			CodecSetYSFMode(tt.mode)
+			if err := CodecCheckSettings(); (err != nil) != tt.wantErr {
+				t.Errorf("CodecCheckSettings() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
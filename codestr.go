@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strconv"
+	"unicode"
+)
+
+// Returns the code str spelling the given string digit by digit.
+func codeStrForDigits(digits string) string {
+	var res string
+	for i := 0; i < len(digits); i++ {
+		res += "0" + string(digits[i])
+	}
+	return res
+}
+
+// Returns the code str for a count. Numbers below 100 have their own code pair, bigger ones are spelled.
+func codeStrForCount(n int) string {
+	if n >= 0 && n < 100 {
+		return strconv.Itoa(n/10) + strconv.Itoa(n%10)
+	}
+	return codeStrForDigits(strconv.Itoa(n))
+}
+
+// Returns true if all code pairs of codeStr are available according to hasCodePair.
+func codeStrIsAvailable(codeStr string, hasCodePair func(codePair string) bool) bool {
+	if len(codeStr) == 0 || len(codeStr)%2 != 0 {
+		return false
+	}
+	for i := 0; i < len(codeStr); i += 2 {
+		if !hasCodePair(codeStr[i : i+2]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the code str spelling the given text. Letters are spelled with the phonetic alphabet if phonetic is
+// true. Characters without a code pair are skipped.
+func codeStrForText(text string, phonetic bool) string {
+	var res string
+	for _, c := range text {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+			if phonetic {
+				res += "P" + string(unicode.ToUpper(c))
+			} else {
+				res += "A" + string(unicode.ToUpper(c))
+			}
+		case c >= '0' && c <= '9':
+			res += "0" + string(c)
+		case c == '-':
+			res += "DS"
+		case c == '.':
+			res += "DT"
+		case c == '/':
+			res += "SL"
+		}
+	}
+	return res
+}
+
+// Code pairs naming the type of the target in "connected to" announcements.
+var codeStrConnectedTargetTypes = map[string]bool{
+	"RF": true, "NO": true, "EL": true, "HS": true, "HI": true, "YS": true, "NX": true, "FC": true, "DP": true,
+	"IP": true, "SV": true, "RM": true,
+}
+
+// Returns true if the code pair spells a character.
+func codeStrIsSpelledChar(codePair string) bool {
+	switch codePair[0] {
+	case '0', numberMarkupChar:
+		return codePair[1] >= '0' && codePair[1] <= '9'
+	case 'A', 'P':
+		return codePair[1] >= 'A' && codePair[1] <= 'Z'
+	}
+	return codePair == "DS" || codePair == "DT" || codePair == "SL"
+}
+
+// Replaces the target after the first "connected to" in codeStr, made of spelled characters and target type code
+// pairs, keeping the rest of the code str. Returns false if there's no "connected to".
+func codeStrReplaceConnectedTarget(codeStr string, target string) (string, bool) {
+	pos := placeholderIndex(codeStr, "CT")
+	if pos < 0 {
+		return codeStr, false
+	}
+	start := pos + 2
+	end := start
+	for ; end+2 <= len(codeStr); end += 2 {
+		codePair := codeStr[end : end+2]
+		if !codeStrIsSpelledChar(codePair) && !codeStrConnectedTargetTypes[codePair] {
+			break
+		}
+	}
+	return codeStr[:start] + target + codeStr[end:], true
+}
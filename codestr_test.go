@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestCodeStrForCount(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "00"},
+		{7, "07"},
+		{42, "42"},
+		{99, "99"},
+		{100, "010000"},
+		{2161, "02010601"},
+	}
+	for _, tt := range tests {
+		if got := codeStrForCount(tt.n); got != tt.want {
+			t.Errorf("codeStrForCount(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestCodeStrForText(t *testing.T) {
+	tests := []struct {
+		text     string
+		phonetic bool
+		want     string
+	}{
+		{"HA2NON", true, "PHPA02PNPOPN"},
+		{"dcs001", false, "ADACAS000001"},
+		{"bm-hu.net/2", false, "ABAMDSAHAUDTANAEATSL02"},
+		{"a b_c", false, "AAABAC"},
+		{"", false, ""},
+	}
+	for _, tt := range tests {
+		if got := codeStrForText(tt.text, tt.phonetic); got != tt.want {
+			t.Errorf("codeStrForText(%q, %v) = %q, want %q", tt.text, tt.phonetic, got, tt.want)
+		}
+	}
+}
+
+func TestCodeStrIsAvailable(t *testing.T) {
+	tests := []struct {
+		codeStr string
+		want    bool
+	}{
+		{"CTTG", true},
+		{"CTTS", false},
+		{"CTT", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := codeStrIsAvailable(tt.codeStr, hasCodePairsExcept("TS")); got != tt.want {
+			t.Errorf("codeStrIsAvailable(%q) = %v, want %v", tt.codeStr, got, tt.want)
+		}
+	}
+}
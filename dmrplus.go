@@ -0,0 +1,38 @@
+package main
+
+// DMR+ master status API URL. {id} is replaced by the client ID, {host} by the master's host or IP.
+var DMRPlusAPIURL string
+
+// Response of the DMR+ master status API.
+type dmrplusMasterStatus struct {
+	Master    string             `json:"master"`
+	Reflector networkTalkgroupID `json:"reflector"`
+	Talkgroup networkTalkgroupID `json:"talkgroup"`
+}
+
+type dmrplusStatusProvider struct{}
+
+func (p *dmrplusStatusProvider) Networks() []string {
+	return []string{"DMR+"}
+}
+
+func (p *dmrplusStatusProvider) FetchClientData(clientId uint32, sd *networkServerData) (networkClientData, error) {
+	var result networkClientData
+	var status dmrplusMasterStatus
+	if err := getJson(networkExpandURLTemplate(DMRPlusAPIURL, clientId, sd), &status); err != nil {
+		return result, err
+	}
+
+	result.ServerName = status.Master
+	if status.Reflector != "" {
+		result.ReflectorSubscriptions = append(result.ReflectorSubscriptions, networkSubscription{Talkgroup: string(status.Reflector)})
+	}
+	if status.Talkgroup != "" {
+		result.DynamicSubscriptions = append(result.DynamicSubscriptions, networkSubscription{Talkgroup: string(status.Talkgroup)})
+	}
+	return result, nil
+}
+
+func (p *dmrplusStatusProvider) GenerateNameCodeStr(hasCodePair func(codePair string) bool) string {
+	return "DP"
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestDMRPlusFetchClientData(t *testing.T) {
+	sd := networkServerData{Network: "DMR+", Name: "DMR+ IPSC2/2622", Host: "10.0.0.5"}
+	networkTestProvider(t, &dmrplusStatusProvider{}, &DMRPlusAPIURL, "/status/{host}/{id}", "/status/10.0.0.5/2161234",
+		sd, hasAllCodePairs, []networkProviderTest{
+			{name: "reflector and talkgroup", response: `{"master": "IPSC2", "reflector": 4012, "talkgroup": "262"}`,
+				want: networkClientData{
+					ServerName:             "IPSC2",
+					DynamicSubscriptions:   []networkSubscription{{Talkgroup: "262"}},
+					ReflectorSubscriptions: []networkSubscription{{Talkgroup: "4012"}},
+				},
+				wantCodeStr: "DP" + codeStrForText("IPSC2", false) + "LKDNTG#2#6#2" + "LKRF#4#0#1#2"},
+			// The server ID from the server list is used without the master's name.
+			{name: "empty fields", response: `{"master": "", "reflector": 0, "talkgroup": null}`,
+				wantCodeStr: "DP02060202"},
+			{name: "missing fields", response: `{}`, wantCodeStr: "DP02060202"},
+			{name: "http error", wantErr: true},
+			{name: "invalid json", response: `{"master": 1}`, wantErr: true},
+		})
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Resolves host names to IP addresses. The returned TTL tells how long the addresses can be used.
+type DNSResolver interface {
+	LookupHost(host string) ([]string, time.Duration, error)
+}
+
+// TTLs of answers are clamped to this range.
+var DNSMinTTL = 30 * time.Second
+var DNSMaxTTL = 24 * time.Hour
+
+// The system resolver doesn't tell the TTL, its answers are used for this long.
+var DNSSystemTTL = time.Hour
+
+var DNSQueryTimeout = 2 * time.Second
+
+const dnsTypeA = 1
+const dnsTypeAAAA = 28
+
+var dnsResolver DNSResolver = &dnsSystemResolver{}
+
+type dnsSystemResolver struct{}
+
+func (r *dnsSystemResolver) LookupHost(host string) ([]string, time.Duration, error) {
+	addrs, err := net.LookupHost(host)
+	return addrs, DNSSystemTTL, err
+}
+
+// Queries the given DNS server over UDP. Truncated answers are asked again over TCP.
+type dnsServerResolver struct {
+	Addr string
+}
+
+func (r *dnsServerResolver) exchange(query []byte) ([]byte, error) {
+	answer, err := r.exchangeUDP(query)
+	if err == nil && dnsIsTruncated(answer) {
+		return r.exchangeTCP(query)
+	}
+	return answer, err
+}
+
+func (r *dnsServerResolver) exchangeUDP(query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", r.Addr, DNSQueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(DNSQueryTimeout))
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, 1500)
+	n, err := conn.Read(answer)
+	if err != nil {
+		return nil, err
+	}
+	return answer[:n], nil
+}
+
+// Messages over TCP are prefixed with their length (RFC 1035 4.2.2).
+func (r *dnsServerResolver) exchangeTCP(query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", r.Addr, DNSQueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(DNSQueryTimeout))
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(msg, query...)); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+func (r *dnsServerResolver) LookupHost(host string) ([]string, time.Duration, error) {
+	return dnsLookupHost(host, r.exchange)
+}
+
+// Queries the given DNS over HTTPS (RFC 8484) URL.
+type dnsDoHResolver struct {
+	URL string
+}
+
+func (r *dnsDoHResolver) exchange(query []byte) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, r.URL, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := NetworkHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 65535))
+}
+
+func (r *dnsDoHResolver) LookupHost(host string) ([]string, time.Duration, error) {
+	return dnsLookupHost(host, r.exchange)
+}
+
+// Returns the resolver for the given spec: empty for the system resolver, an https:// URL for DNS over HTTPS,
+// otherwise a DNS server address with an optional port.
+func DNSNewResolver(spec string) (DNSResolver, error) {
+	switch {
+	case spec == "":
+		return &dnsSystemResolver{}, nil
+	case strings.HasPrefix(spec, "https://"):
+		return &dnsDoHResolver{URL: spec}, nil
+	}
+
+	if _, _, err := net.SplitHostPort(spec); err != nil {
+		spec = net.JoinHostPort(spec, "53")
+	}
+	if _, _, err := net.SplitHostPort(spec); err != nil {
+		return nil, fmt.Errorf("invalid dns server %s", spec)
+	}
+	return &dnsServerResolver{Addr: spec}, nil
+}
+
+func DNSSetResolver(resolver DNSResolver) {
+	dnsResolver = resolver
+}
+
+// DNSLookupHost resolves host with the configured resolver. IP addresses are returned as is.
+func DNSLookupHost(host string) ([]string, time.Duration, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, DNSMaxTTL, nil
+	}
+
+	addrs, ttl, err := dnsResolver.LookupHost(host)
+	return addrs, min(max(ttl, DNSMinTTL), DNSMaxTTL), err
+}
+
+func dnsEncodeQuery(id uint16, host string, qtype uint16) ([]byte, error) {
+	var buf bytes.Buffer
+	// Header with the recursion desired flag and one question.
+	binary.Write(&buf, binary.BigEndian, [6]uint16{id, 0x0100, 1, 0, 0, 0})
+
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("invalid host name %s", host)
+		}
+		buf.WriteByte(byte(len(label)))
+		buf.WriteString(label)
+	}
+	buf.WriteByte(0)
+	binary.Write(&buf, binary.BigEndian, [2]uint16{qtype, 1})
+	return buf.Bytes(), nil
+}
+
+var errDNSShortMessage = errors.New("dns message too short")
+var errDNSTruncated = errors.New("dns answer truncated")
+
+// Returns true if the message has the truncated (TC) flag set.
+func dnsIsTruncated(msg []byte) bool {
+	return len(msg) >= 3 && msg[2]&0x02 != 0
+}
+
+// Returns the offset after the name starting at off.
+func dnsSkipName(msg []byte, off int) (int, error) {
+	for {
+		if off >= len(msg) {
+			return 0, errDNSShortMessage
+		}
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			return off + 1, nil
+		case l&0xc0 == 0xc0:
+			// Compression pointer, the name ends here.
+			return off + 2, nil
+		}
+		off += 1 + l
+	}
+}
+
+// Returns the addresses of the given type in the answer, and the lowest TTL of the answer records.
+func dnsDecodeAnswer(msg []byte, id uint16, qtype uint16) ([]string, time.Duration, error) {
+	if len(msg) < 12 {
+		return nil, 0, errDNSShortMessage
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != id {
+		return nil, 0, errors.New("dns answer id mismatch")
+	}
+	// A truncated answer may miss addresses, it's not used.
+	if dnsIsTruncated(msg) {
+		return nil, 0, errDNSTruncated
+	}
+	if rcode := msg[3] & 0x0f; rcode != 0 {
+		return nil, 0, fmt.Errorf("dns answer rcode %d", rcode)
+	}
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+
+	off := 12
+	var err error
+	for i := 0; i < qdcount; i++ {
+		if off, err = dnsSkipName(msg, off); err != nil {
+			return nil, 0, err
+		}
+		off += 4
+	}
+
+	var addrs []string
+	var ttl time.Duration
+	for i := 0; i < ancount; i++ {
+		if off, err = dnsSkipName(msg, off); err != nil {
+			return nil, 0, err
+		}
+		if off+10 > len(msg) {
+			return nil, 0, errDNSShortMessage
+		}
+		rtype := binary.BigEndian.Uint16(msg[off:])
+		rttl := time.Duration(binary.BigEndian.Uint32(msg[off+4:])) * time.Second
+		rdlength := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+rdlength > len(msg) {
+			return nil, 0, errDNSShortMessage
+		}
+
+		// CNAME records count for the TTL too.
+		if i == 0 || rttl < ttl {
+			ttl = rttl
+		}
+		if rtype == qtype && (rdlength == net.IPv4len || rdlength == net.IPv6len) {
+			addrs = append(addrs, net.IP(msg[off:off+rdlength]).String())
+		}
+		off += rdlength
+	}
+	return addrs, ttl, nil
+}
+
+// Queries both A and AAAA records of host using exchange to send the query and get the answer.
+func dnsLookupHost(host string, exchange func(query []byte) ([]byte, error)) ([]string, time.Duration, error) {
+	var addrs []string
+	var ttl time.Duration
+	var lastErr error
+
+	for _, qtype := range []uint16{dnsTypeA, dnsTypeAAAA} {
+		id := uint16(rand.Intn(0x10000))
+		query, err := dnsEncodeQuery(id, host, qtype)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		answer, err := exchange(query)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		qaddrs, qttl, err := dnsDecodeAnswer(answer, id, qtype)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(qaddrs) > 0 {
+			if len(addrs) == 0 || qttl < ttl {
+				ttl = qttl
+			}
+			addrs = append(addrs, qaddrs...)
+		}
+	}
+
+	if len(addrs) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no addresses for %s", host)
+		}
+		return nil, 0, lastErr
+	}
+	return addrs, ttl, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type dnsTestRecord struct {
+	rtype uint16
+	ttl   uint32
+	data  []byte
+}
+
+// Builds an answer to the query of dnsEncodeQuery(id, "example.org", qtype). Record names point to the question.
+func dnsTestAnswer(id uint16, flags uint16, qtype uint16, records ...dnsTestRecord) []byte {
+	msg := binary.BigEndian.AppendUint16(nil, id)
+	msg = binary.BigEndian.AppendUint16(msg, 0x8180|flags)
+	msg = binary.BigEndian.AppendUint16(msg, 1)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(records)))
+	msg = append(msg, 0, 0, 0, 0)
+	msg = append(msg, "\x07example\x03org\x00"...)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, 1)
+	for _, r := range records {
+		msg = append(msg, 0xc0, 12)
+		msg = binary.BigEndian.AppendUint16(msg, r.rtype)
+		msg = binary.BigEndian.AppendUint16(msg, 1)
+		msg = binary.BigEndian.AppendUint32(msg, r.ttl)
+		msg = binary.BigEndian.AppendUint16(msg, uint16(len(r.data)))
+		msg = append(msg, r.data...)
+	}
+	return msg
+}
+
+func TestDNSEncodeQuery(t *testing.T) {
+	query, err := dnsEncodeQuery(0x1234, "example.org.", dnsTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte("\x12\x34\x01\x00\x00\x01\x00\x00\x00\x00\x00\x00\x07example\x03org\x00\x00\x01\x00\x01")
+	if !bytes.Equal(query, want) {
+		t.Errorf("got %q, want %q", query, want)
+	}
+
+	for _, host := range []string{"", "a..org", string(make([]byte, 64)) + ".org"} {
+		if _, err := dnsEncodeQuery(1, host, dnsTypeA); err == nil {
+			t.Errorf("expected an error for %q", host)
+		}
+	}
+}
+
+func TestDNSDecodeAnswer(t *testing.T) {
+	cname := dnsTestRecord{5, 60, []byte("\x03www\xc0\x0c")}
+	a1 := dnsTestRecord{dnsTypeA, 300, []byte{10, 0, 0, 1}}
+	a2 := dnsTestRecord{dnsTypeA, 120, []byte{10, 0, 0, 2}}
+	aaaa := dnsTestRecord{dnsTypeAAAA, 300, net.ParseIP("2001:db8::1")}
+
+	tests := []struct {
+		name    string
+		msg     []byte
+		qtype   uint16
+		want    []string
+		wantTTL time.Duration
+		wantErr bool
+	}{
+		{"a records", dnsTestAnswer(1, 0, dnsTypeA, a1, a2), dnsTypeA, []string{"10.0.0.1", "10.0.0.2"}, 120 * time.Second, false},
+		{"cname ttl", dnsTestAnswer(1, 0, dnsTypeA, cname, a1), dnsTypeA, []string{"10.0.0.1"}, 60 * time.Second, false},
+		{"aaaa", dnsTestAnswer(1, 0, dnsTypeAAAA, aaaa), dnsTypeAAAA, []string{"2001:db8::1"}, 300 * time.Second, false},
+		{"other type skipped", dnsTestAnswer(1, 0, dnsTypeAAAA, a1), dnsTypeAAAA, nil, 300 * time.Second, false},
+		{"no answer", dnsTestAnswer(1, 0, dnsTypeA), dnsTypeA, nil, 0, false},
+		{"id mismatch", dnsTestAnswer(2, 0, dnsTypeA, a1), dnsTypeA, nil, 0, true},
+		{"nxdomain", dnsTestAnswer(1, 3, dnsTypeA), dnsTypeA, nil, 0, true},
+		{"truncated", dnsTestAnswer(1, 0x0200, dnsTypeA, a1), dnsTypeA, nil, 0, true},
+		{"short header", []byte{0, 1, 0x81}, dnsTypeA, nil, 0, true},
+		{"short record", dnsTestAnswer(1, 0, dnsTypeA, a1)[:40], dnsTypeA, nil, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addrs, ttl, err := dnsDecodeAnswer(tt.msg, 1, tt.qtype)
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(addrs, tt.want) || ttl != tt.wantTTL {
+				t.Errorf("got %v, %v, %v, want %v, %v, error %v", addrs, ttl, err, tt.want, tt.wantTTL, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDNSLookupHost(t *testing.T) {
+	exchange := func(query []byte) ([]byte, error) {
+		id := binary.BigEndian.Uint16(query)
+		if qtype := binary.BigEndian.Uint16(query[len(query)-4:]); qtype == dnsTypeAAAA {
+			return dnsTestAnswer(id, 0, qtype, dnsTestRecord{qtype, 60, net.ParseIP("2001:db8::1")}), nil
+		}
+		return dnsTestAnswer(id, 0, dnsTypeA, dnsTestRecord{dnsTypeA, 300, []byte{10, 0, 0, 1}}), nil
+	}
+
+	addrs, ttl, err := dnsLookupHost("example.org", exchange)
+	if err != nil || !reflect.DeepEqual(addrs, []string{"10.0.0.1", "2001:db8::1"}) || ttl != time.Minute {
+		t.Errorf("got %v, %v, %v", addrs, ttl, err)
+	}
+}
+
+// Answers queries truncated over UDP and in full over TCP on the same port.
+func TestDNSServerResolverTCPRetry(t *testing.T) {
+	udpConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udpConn.Close()
+	tcpListener, err := net.Listen("tcp", udpConn.LocalAddr().String())
+	if err != nil {
+		t.Skip("can't listen on tcp with the udp port: ", err)
+	}
+	defer tcpListener.Close()
+
+	answer := func(query []byte, flags uint16) []byte {
+		id := binary.BigEndian.Uint16(query)
+		qtype := binary.BigEndian.Uint16(query[len(query)-4:])
+		if qtype != dnsTypeA {
+			return dnsTestAnswer(id, flags, qtype)
+		}
+		return dnsTestAnswer(id, flags, qtype, dnsTestRecord{dnsTypeA, 300, []byte{10, 0, 0, 1}})
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := udpConn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			udpConn.WriteTo(answer(buf[:n], 0x0200), addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := tcpListener.Accept()
+			if err != nil {
+				return
+			}
+			var length [2]byte
+			io.ReadFull(conn, length[:])
+			query := make([]byte, binary.BigEndian.Uint16(length[:]))
+			io.ReadFull(conn, query)
+			msg := answer(query, 0)
+			conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(msg))), msg...))
+			conn.Close()
+		}
+	}()
+
+	r := &dnsServerResolver{Addr: udpConn.LocalAddr().String()}
+	addrs, ttl, err := r.LookupHost("example.org")
+	if err != nil || !reflect.DeepEqual(addrs, []string{"10.0.0.1"}) || ttl != 300*time.Second {
+		t.Errorf("got %v, %v, %v", addrs, ttl, err)
+	}
+}
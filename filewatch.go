@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// Tracks the modification time of a file which is reloaded when it changes.
+type fileWatch struct {
+	modTime time.Time
+}
+
+// Calls load if the file at path has been modified since the last successful load. name is the file's contents
+// in log messages. If load fails, the file is loaded again on the next update.
+func (w *fileWatch) update(name string, path string, load func() error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		log.Printf("%s stat error: %v\n", name, err)
+		return
+	}
+	if fi.ModTime().Equal(w.modTime) {
+		return
+	}
+
+	log.Printf("loading %s from %s\n", name, path)
+	if err := load(); err != nil {
+		log.Printf("%s load error: %v\n", name, err)
+		return
+	}
+	w.modTime = fi.ModTime()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileWatchUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(path, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	touch := func(d time.Duration) {
+		mt := time.Now().Add(d)
+		if err := os.Chtimes(path, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var w fileWatch
+	var loads int
+	var loadErr error
+	load := func() error {
+		loads++
+		return loadErr
+	}
+
+	tests := []struct {
+		name      string
+		change    func()
+		loadErr   error
+		wantLoads int
+	}{
+		{"first load", func() {}, nil, 1},
+		{"unchanged", func() {}, nil, 1},
+		{"modified", func() { touch(time.Hour) }, nil, 2},
+		{"failed load", func() { touch(2 * time.Hour) }, errors.New("parse error"), 3},
+		{"retried after a failed load", func() {}, nil, 4},
+		{"unchanged after the retry", func() {}, nil, 4},
+		{"missing file", func() { os.Remove(path) }, nil, 4},
+	}
+	for _, tt := range tests {
+		tt.change()
+		loadErr = tt.loadErr
+		w.update("test list", path, load)
+		if loads != tt.wantLoads {
+			t.Errorf("%s: %d loads, want %d", tt.name, loads, tt.wantLoads)
+		}
+	}
+}
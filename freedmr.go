@@ -0,0 +1,52 @@
+package main
+
+// FreeDMR/HBlink peer status API URL. {id} is replaced by the client ID, {host} by the server's host, as every
+// server has its own API.
+var FreeDMRAPIURL string
+
+// Response of the FreeDMR/HBlink peer status API, similar to the peer's options.
+type freedmrPeerStatus struct {
+	Slot1Static  []networkTalkgroupID `json:"ts1_static"`
+	Slot2Static  []networkTalkgroupID `json:"ts2_static"`
+	Slot1Dynamic networkTalkgroupID   `json:"ts1_dynamic"`
+	Slot2Dynamic networkTalkgroupID   `json:"ts2_dynamic"`
+	Dial         networkTalkgroupID   `json:"dial"` // Linked reflector.
+}
+
+type freedmrStatusProvider struct{}
+
+func (p *freedmrStatusProvider) Networks() []string {
+	return []string{"FreeDMR", "HBlink"}
+}
+
+func (p *freedmrStatusProvider) FetchClientData(clientId uint32, sd *networkServerData) (networkClientData, error) {
+	var result networkClientData
+	var status freedmrPeerStatus
+	if err := getJson(networkExpandURLTemplate(FreeDMRAPIURL, clientId, sd), &status); err != nil {
+		return result, err
+	}
+
+	for slot, tgs := range [][]networkTalkgroupID{status.Slot1Static, status.Slot2Static} {
+		for _, tg := range tgs {
+			if tg != "" {
+				result.StaticSubscriptions = append(result.StaticSubscriptions, networkSubscription{Talkgroup: string(tg), Slot: uint8(slot + 1)})
+			}
+		}
+	}
+	for slot, tg := range []networkTalkgroupID{status.Slot1Dynamic, status.Slot2Dynamic} {
+		if tg != "" {
+			result.DynamicSubscriptions = append(result.DynamicSubscriptions, networkSubscription{Talkgroup: string(tg), Slot: uint8(slot + 1)})
+		}
+	}
+	if status.Dial != "" {
+		result.ReflectorSubscriptions = append(result.ReflectorSubscriptions, networkSubscription{Talkgroup: string(status.Dial)})
+	}
+	return result, nil
+}
+
+func (p *freedmrStatusProvider) GenerateNameCodeStr(hasCodePair func(codePair string) bool) string {
+	if hasCodePair("FD") {
+		return "FD"
+	}
+	return "HB"
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestFreeDMRFetchClientData(t *testing.T) {
+	sd := networkServerData{Network: "FreeDMR", Name: "FreeDMR/2341", Host: "freedmr.example.org"}
+	full := `{"ts1_static": [91, "2161", 0], "ts2_static": [], "ts1_dynamic": 9990, "ts2_dynamic": null, "dial": 4012}`
+	fullData := networkClientData{
+		StaticSubscriptions:    []networkSubscription{{"91", 1}, {"2161", 1}},
+		DynamicSubscriptions:   []networkSubscription{{"9990", 1}},
+		ReflectorSubscriptions: []networkSubscription{{Talkgroup: "4012"}},
+	}
+	fullCodeStr := "02030401" + "TS01LKSTGS#9#1ND#2#1#6#1" + "TS01LKDNTG#9#9#9#0" + "LKRF#4#0#1#2"
+
+	networkTestProvider(t, &freedmrStatusProvider{}, &FreeDMRAPIURL, "/{host}/peer/{id}",
+		"/freedmr.example.org/peer/2161234", sd, hasAllCodePairs, []networkProviderTest{
+			{name: "all fields", response: full, want: fullData, wantCodeStr: "FD" + fullCodeStr},
+			{name: "second slot", response: `{"ts2_static": [262], "ts2_dynamic": "91"}`,
+				want: networkClientData{
+					StaticSubscriptions:  []networkSubscription{{"262", 2}},
+					DynamicSubscriptions: []networkSubscription{{"91", 2}},
+				},
+				wantCodeStr: "FD02030401" + "TS02LKSTTG#2#6#2" + "TS02LKDNTG#9#1"},
+			{name: "empty fields", response: `{"ts1_static": null, "ts2_static": [0], "ts1_dynamic": 0, "dial": null}`,
+				wantCodeStr: "FD02030401"},
+			{name: "http error", wantErr: true},
+			{name: "invalid json", response: `[]`, wantErr: true},
+		})
+
+	// Voices without the FreeDMR name say HBlink.
+	networkTestProvider(t, &freedmrStatusProvider{}, &FreeDMRAPIURL, "/{host}/peer/{id}",
+		"/freedmr.example.org/peer/2161234", sd, hasCodePairsExcept("FD"), []networkProviderTest{
+			{name: "no fd code pair", response: full, want: fullData, wantCodeStr: "HB" + fullCodeStr},
+		})
+}
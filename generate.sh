@@ -1,5 +1,27 @@
 #!/bin/bash
-go-bindata -nocompress \
-	voices/v0/dmr voices/v0/dstar voices/v0/p25 \
+dirs="voices/v0/dmr voices/v0/dstar voices/v0/p25 \
 	voices/v1/srf-male-en/dmr voices/v1/srf-male-en/dstar voices/v1/srf-male-en/p25 \
-	voices/v1/srf-female-en/dmr voices/v1/srf-female-en/dstar voices/v1/srf-female-en/p25
+	voices/v1/srf-female-en/dmr voices/v1/srf-female-en/dstar voices/v1/srf-female-en/p25"
+
+# Native YSF and NXDN asset directories and voice packs of other languages are optional.
+for dir in voices/v0/ysf-dn voices/v0/ysf-vw voices/v0/nxdn voices/v1/*/ysf-dn voices/v1/*/ysf-vw voices/v1/*/nxdn \
+	voices/v1/*/dmr voices/v1/*/dstar voices/v1/*/p25; do
+	known=0
+	for d in $dirs; do
+		if [ "$d" = "$dir" ]; then
+			known=1
+		fi
+	done
+	if [ $known = 0 ] && [ -d "$dir" ]; then
+		dirs="$dirs $dir"
+	fi
+done
+
+# Voice pack manifests are in the pack's root directory.
+for manifest in voices/v1/*/manifest.json; do
+	if [ -f "$manifest" ]; then
+		dirs="$dirs $(dirname "$manifest")"
+	fi
+done
+
+go-bindata -nocompress $dirs
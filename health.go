@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// The UDP listening loop wakes up at least this often, so a heartbeat older than healthMaxHeartbeatAge means the
+// loop is stuck.
+const healthUDPLoopWakeupInterval = time.Second
+const healthMaxHeartbeatAge = 5 * healthUDPLoopWakeupInterval
+
+// Voice asset directories which have to contain at least one .ambe file for the server to be ready.
+var healthRequiredVoiceDirs = []string{
+	"voices/v0/dmr", "voices/v0/dstar", "voices/v0/p25",
+	"voices/v1/srf-male-en/dmr", "voices/v1/srf-male-en/dstar", "voices/v1/srf-male-en/p25",
+	"voices/v1/srf-female-en/dmr", "voices/v1/srf-female-en/dstar", "voices/v1/srf-female-en/p25",
+}
+
+var healthStartTime = time.Now()
+var healthUDPLoopHeartbeat atomic.Int64
+var healthServerListGracePeriod = 30 * time.Second
+
+// HealthUDPLoopHeartbeat should be called on each iteration of the UDP listening loop.
+func HealthUDPLoopHeartbeat() {
+	healthUDPLoopHeartbeat.Store(time.Now().UnixNano())
+}
+
+func healthUDPLoopAlive() bool {
+	lastHeartbeat := healthUDPLoopHeartbeat.Load()
+	if lastHeartbeat == 0 {
+		return false
+	}
+	return time.Since(time.Unix(0, lastHeartbeat)) < healthMaxHeartbeatAge
+}
+
+func healthVoicesLoaded() (bool, string) {
+	for _, dir := range healthRequiredVoiceDirs {
+		if !assetDirHasFiles(dir) {
+			return false, dir
+		}
+	}
+	return true, ""
+}
+
+func healthHandleHealthz(w http.ResponseWriter, r *http.Request) {
+	if !healthUDPLoopAlive() {
+		http.Error(w, "udp loop not alive", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func healthHandleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !healthUDPLoopAlive() {
+		http.Error(w, "udp loop not alive", http.StatusServiceUnavailable)
+		return
+	}
+	if ok, missingDir := healthVoicesLoaded(); !ok {
+		http.Error(w, "voice pack not loaded: "+missingDir, http.StatusServiceUnavailable)
+		return
+	}
+	if !NetworkServerListPopulated() && time.Since(healthStartTime) < healthServerListGracePeriod {
+		http.Error(w, "server list not populated yet", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func healthHandleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"networkcache": NetworkCacheGetStats(),
+		"voices":       VoiceGetStats(),
+	})
+}
+
+// HealthProcess serves the /healthz, /readyz and /stats endpoints on the given address.
+func HealthProcess(addr string, serverListGracePeriod time.Duration) {
+	healthServerListGracePeriod = serverListGracePeriod
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthHandleHealthz)
+	mux.HandleFunc("/readyz", healthHandleReadyz)
+	mux.HandleFunc("/stats", healthHandleStats)
+
+	log.Printf("starting health http server on %s\n", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Println("health http server error: ", err)
+	}
+}
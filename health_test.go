@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Sets the health state for a test and restores it when it ends.
+func healthTestState(t *testing.T, heartbeat time.Time, voiceDirs []string, serverList bool, started time.Time) {
+	savedHeartbeat, savedDirs, savedStart := healthUDPLoopHeartbeat.Load(), healthRequiredVoiceDirs, healthStartTime
+	networkServerIPHostsMutex.Lock()
+	savedList := networkServerIPHosts
+	networkServerIPHostsMutex.Unlock()
+	t.Cleanup(func() {
+		healthUDPLoopHeartbeat.Store(savedHeartbeat)
+		healthRequiredVoiceDirs, healthStartTime = savedDirs, savedStart
+		networkServerIPHostsMutex.Lock()
+		networkServerIPHosts = savedList
+		networkServerIPHostsMutex.Unlock()
+	})
+
+	if heartbeat.IsZero() {
+		healthUDPLoopHeartbeat.Store(0)
+	} else {
+		healthUDPLoopHeartbeat.Store(heartbeat.UnixNano())
+	}
+	healthRequiredVoiceDirs, healthStartTime = voiceDirs, started
+	list := make(map[networkServerIP]networkServerData)
+	if serverList {
+		list["10.0.0.1"] = networkServerData{Network: "BrandMeister", Name: "2161", Host: "hu.example.org"}
+	}
+	networkServerIPHostsMutex.Lock()
+	networkServerIPHosts = list
+	networkServerIPHostsMutex.Unlock()
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	now := time.Now()
+	longAgo := now.Add(-time.Hour)
+
+	tests := []struct {
+		name       string
+		heartbeat  time.Time
+		voiceDirs  []string
+		serverList bool
+		started    time.Time
+		healthz    int
+		readyz     int
+	}{
+		{"ready", now, nil, true, longAgo, http.StatusOK, http.StatusOK},
+		{"no heartbeat", time.Time{}, nil, true, longAgo, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		{"stuck loop", now.Add(-healthMaxHeartbeatAge - time.Second), nil, true, longAgo,
+			http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		{"missing voice", now, []string{"voices/test/missing"}, true, longAgo, http.StatusOK, http.StatusServiceUnavailable},
+		{"server list pending", now, nil, false, now, http.StatusOK, http.StatusServiceUnavailable},
+		{"server list grace period over", now, nil, false, longAgo, http.StatusOK, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthTestState(t, tt.heartbeat, tt.voiceDirs, tt.serverList, tt.started)
+
+			for path, want := range map[string]int{"/healthz": tt.healthz, "/readyz": tt.readyz} {
+				w := httptest.NewRecorder()
+				handler := healthHandleHealthz
+				if path == "/readyz" {
+					handler = healthHandleReadyz
+				}
+				handler(w, httptest.NewRequest(http.MethodGet, path, nil))
+				if w.Code != want {
+					t.Errorf("%s returned %d (%s), want %d", path, w.Code, w.Body.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthStats(t *testing.T) {
+	networkCacheTestSettings(t, time.Minute, time.Minute, 0)
+
+	getStats := func() (stats struct {
+		NetworkCache networkCacheStats `json:"networkcache"`
+		Voices       voiceStats        `json:"voices"`
+	}) {
+		w := httptest.NewRecorder()
+		healthHandleStats(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("content type %s", ct)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+
+	before := getStats()
+	fetch := func(clientId uint32) (networkClientData, error) { return networkClientData{}, nil }
+	networkCacheGetClientData("test", 3000, fetch)
+	networkCacheGetClientData("test", 3000, fetch)
+	VoiceCountCodePairFallback()
+	after := getStats()
+
+	if after.NetworkCache.Misses != before.NetworkCache.Misses+1 || after.NetworkCache.Hits != before.NetworkCache.Hits+1 {
+		t.Errorf("cache stats %+v, before %+v", after.NetworkCache, before.NetworkCache)
+	}
+	if after.NetworkCache.Entries < 1 {
+		t.Errorf("%d cache entries", after.NetworkCache.Entries)
+	}
+	if after.Voices.CodePairFallbacks != before.Voices.CodePairFallbacks+1 {
+		t.Errorf("voice stats %+v, before %+v", after.Voices, before.Voices)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+var NetworkCacheTTL = 5 * time.Minute
+var NetworkCacheNegativeTTL = 30 * time.Second
+
+// If not 0, cached client data at most this old is served if the network API request fails.
+var NetworkCacheMaxStale time.Duration
+
+type networkCacheEntry struct {
+	data      networkClientData
+	err       error
+	fetchedAt time.Time
+	// The last successfully fetched data, kept for serving stale data after a failed refresh.
+	lastGood          *networkClientData
+	lastGoodFetchedAt time.Time
+}
+
+type networkCacheKey struct {
+	network  string
+	clientId uint32
+}
+
+// An in-flight network API request. Concurrent lookups for the same client wait for it instead of sending their own.
+type networkCacheCall struct {
+	done chan struct{}
+	data networkClientData
+	err  error
+}
+
+type networkCacheStats struct {
+	Hits         uint64 `json:"hits"`
+	Misses       uint64 `json:"misses"`
+	NegativeHits uint64 `json:"negative_hits"`
+	StaleServed  uint64 `json:"stale_served"`
+	Coalesced    uint64 `json:"coalesced"`
+	Errors       uint64 `json:"errors"`
+	Entries      int    `json:"entries"`
+}
+
+var networkCacheEntries = make(map[networkCacheKey]*networkCacheEntry)
+var networkCacheCalls = make(map[networkCacheKey]*networkCacheCall)
+var networkCacheStatsData networkCacheStats
+var networkCacheMutex = &sync.Mutex{}
+
+// Returns client data from the cache, or fetches it with fetch if it's not cached or expired.
+func networkCacheGetClientData(network string, clientId uint32, fetch func(clientId uint32) (networkClientData, error)) (networkClientData, error) {
+	key := networkCacheKey{network, clientId}
+
+	networkCacheMutex.Lock()
+	if entry, ok := networkCacheEntries[key]; ok {
+		if entry.err == nil && time.Since(entry.fetchedAt) < NetworkCacheTTL {
+			networkCacheStatsData.Hits++
+			networkCacheMutex.Unlock()
+			return entry.data, nil
+		}
+		if entry.err != nil && time.Since(entry.fetchedAt) < NetworkCacheNegativeTTL {
+			networkCacheStatsData.NegativeHits++
+			data, err := networkCacheGetStale(entry, entry.err)
+			networkCacheMutex.Unlock()
+			return data, err
+		}
+	}
+
+	if call, ok := networkCacheCalls[key]; ok {
+		networkCacheStatsData.Coalesced++
+		networkCacheMutex.Unlock()
+		<-call.done
+		return call.data, call.err
+	}
+
+	networkCacheStatsData.Misses++
+	call := &networkCacheCall{done: make(chan struct{})}
+	networkCacheCalls[key] = call
+	networkCacheMutex.Unlock()
+
+	data, err := fetch(clientId)
+
+	networkCacheMutex.Lock()
+	entry, ok := networkCacheEntries[key]
+	if !ok {
+		entry = &networkCacheEntry{}
+		networkCacheEntries[key] = entry
+	}
+	entry.data = data
+	entry.err = err
+	entry.fetchedAt = time.Now()
+	if err == nil {
+		entry.lastGood = &data
+		entry.lastGoodFetchedAt = entry.fetchedAt
+	} else {
+		networkCacheStatsData.Errors++
+		data, err = networkCacheGetStale(entry, err)
+	}
+	call.data = data
+	call.err = err
+	delete(networkCacheCalls, key)
+	networkCacheMutex.Unlock()
+
+	close(call.done)
+	return data, err
+}
+
+// Returns the last good data of the entry if serving stale data is enabled and it's not too old, otherwise
+// returns err. Must be called with networkCacheMutex locked.
+func networkCacheGetStale(entry *networkCacheEntry, err error) (networkClientData, error) {
+	if NetworkCacheMaxStale > 0 && entry.lastGood != nil && time.Since(entry.lastGoodFetchedAt) < NetworkCacheMaxStale {
+		networkCacheStatsData.StaleServed++
+		return *entry.lastGood, nil
+	}
+	return networkClientData{}, err
+}
+
+func NetworkCacheGetStats() networkCacheStats {
+	networkCacheMutex.Lock()
+	defer networkCacheMutex.Unlock()
+	stats := networkCacheStatsData
+	stats.Entries = len(networkCacheEntries)
+	return stats
+}
+
+// Removes entries which can't be served anymore, not even as stale data.
+func networkCacheCleanup() {
+	maxAge := NetworkCacheTTL
+	if NetworkCacheNegativeTTL > maxAge {
+		maxAge = NetworkCacheNegativeTTL
+	}
+	if NetworkCacheMaxStale > maxAge {
+		maxAge = NetworkCacheMaxStale
+	}
+
+	networkCacheMutex.Lock()
+	for key, entry := range networkCacheEntries {
+		if time.Since(entry.fetchedAt) >= maxAge && (entry.lastGood == nil || time.Since(entry.lastGoodFetchedAt) >= maxAge) {
+			delete(networkCacheEntries, key)
+		}
+	}
+	networkCacheMutex.Unlock()
+}
+
+func NetworkCacheProcess() {
+	for {
+		time.Sleep(time.Minute)
+		networkCacheCleanup()
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Sets the cache settings for a test and restores them when it ends.
+func networkCacheTestSettings(t *testing.T, ttl, negativeTTL, maxStale time.Duration) {
+	savedTTL, savedNegativeTTL, savedMaxStale := NetworkCacheTTL, NetworkCacheNegativeTTL, NetworkCacheMaxStale
+	t.Cleanup(func() {
+		NetworkCacheTTL, NetworkCacheNegativeTTL, NetworkCacheMaxStale = savedTTL, savedNegativeTTL, savedMaxStale
+	})
+	NetworkCacheTTL, NetworkCacheNegativeTTL, NetworkCacheMaxStale = ttl, negativeTTL, maxStale
+}
+
+func TestNetworkCacheGetClientData(t *testing.T) {
+	errFetch := errors.New("fetch failed")
+	first := networkClientData{ServerName: "first"}
+	second := networkClientData{ServerName: "second"}
+
+	tests := []struct {
+		name                    string
+		ttl, negativeTTL, stale time.Duration
+		firstErr, secondErr     error
+		sleep                   time.Duration
+		want                    networkClientData
+		wantErr                 error
+		wantFetches             int
+	}{
+		{"hit", time.Minute, time.Minute, 0, nil, nil, 0, first, nil, 1},
+		{"expired", time.Millisecond, time.Minute, 0, nil, nil, 5 * time.Millisecond, second, nil, 2},
+		{"negative hit", time.Minute, time.Minute, 0, errFetch, nil, 0, networkClientData{}, errFetch, 1},
+		{"negative expired", time.Minute, time.Millisecond, 0, errFetch, nil, 5 * time.Millisecond, second, nil, 2},
+		{"stale served", time.Millisecond, time.Minute, time.Minute, nil, errFetch, 5 * time.Millisecond, first, nil, 2},
+		{"stale disabled", time.Millisecond, time.Minute, 0, nil, errFetch, 5 * time.Millisecond, networkClientData{}, errFetch, 2},
+		{"too stale", time.Millisecond, time.Minute, 2 * time.Millisecond, nil, errFetch, 5 * time.Millisecond,
+			networkClientData{}, errFetch, 2},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networkCacheTestSettings(t, tt.ttl, tt.negativeTTL, tt.stale)
+			fetches := 0
+			fetch := func(clientId uint32) (networkClientData, error) {
+				fetches++
+				if fetches == 1 {
+					return first, tt.firstErr
+				}
+				return second, tt.secondErr
+			}
+
+			// Each case has its own client, so cases don't share entries.
+			clientId := uint32(1000 + i)
+			networkCacheGetClientData("test", clientId, fetch)
+			time.Sleep(tt.sleep)
+			data, err := networkCacheGetClientData("test", clientId, fetch)
+			if data.ServerName != tt.want.ServerName || err != tt.wantErr || fetches != tt.wantFetches {
+				t.Errorf("got %q, %v after %d fetches, want %q, %v after %d", data.ServerName, err, fetches,
+					tt.want.ServerName, tt.wantErr, tt.wantFetches)
+			}
+		})
+	}
+}
+
+func TestNetworkCacheCoalesce(t *testing.T) {
+	networkCacheTestSettings(t, time.Minute, time.Minute, 0)
+
+	var fetches int32
+	release := make(chan struct{})
+	fetch := func(clientId uint32) (networkClientData, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return networkClientData{ServerName: "server"}, nil
+	}
+
+	const lookups = 10
+	var wg sync.WaitGroup
+	results := make(chan networkClientData, lookups)
+	for i := 0; i < lookups; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, _ := networkCacheGetClientData("test", 2000, fetch)
+			results <- data
+		}()
+	}
+	// Lets all lookups reach the in-flight call before it finishes.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if fetches != 1 {
+		t.Errorf("%d fetches, want 1", fetches)
+	}
+	for data := range results {
+		if data.ServerName != "server" {
+			t.Errorf("got %q, want \"server\"", data.ServerName)
+		}
+	}
+}
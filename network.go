@@ -0,0 +1,565 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type networkServerData struct {
+	Network string
+	Name    string
+	Host    string
+}
+
+type networkSubscription struct {
+	Talkgroup string
+	Slot      uint8 // 0 for simplex devices.
+}
+
+// Device status, for networks which have it.
+type networkDeviceData struct {
+	Blocked            bool
+	CallRouting        bool
+	LastHeardTalkgroup string
+}
+
+// Status of a client connected to a network server. Providers convert their network's API responses to this.
+type networkClientData struct {
+	// Spelled instead of the server ID from the server list if not empty.
+	ServerName             string `json:"-"`
+	StaticSubscriptions    []networkSubscription
+	DynamicSubscriptions   []networkSubscription
+	ReflectorSubscriptions []networkSubscription
+	Device                 networkDeviceData
+}
+
+// Gets client status from a network's API.
+type NetworkStatusProvider interface {
+	// Network names in the server list handled by this provider.
+	Networks() []string
+	// Fetches the status of the client connected to the given server.
+	FetchClientData(clientId uint32, sd *networkServerData) (networkClientData, error)
+	// Returns the code str for the network's name.
+	GenerateNameCodeStr(hasCodePair func(codePair string) bool) string
+}
+
+// Talkgroup ID which can be a JSON number or string in API responses.
+type networkTalkgroupID string
+
+func (t *networkTalkgroupID) UnmarshalJSON(data []byte) error {
+	str := strings.Trim(string(data), "\"")
+	if str == "null" || str == "0" {
+		str = ""
+	}
+	*t = networkTalkgroupID(str)
+	return nil
+}
+
+// Numeric talkgroup IDs are written as JSON numbers, like the APIs do.
+func (t networkTalkgroupID) MarshalJSON() ([]byte, error) {
+	if t == "" {
+		return []byte("null"), nil
+	}
+	if n, err := strconv.ParseUint(string(t), 10, 32); err == nil && strconv.FormatUint(n, 10) == string(t) {
+		return []byte(t), nil
+	}
+	return json.Marshal(string(t))
+}
+
+type networkServerIP string
+
+// Talkgroup lists longer than this are announced only by their count. 0 disables shortening.
+var NetworkMaxListedTalkgroups = 4
+
+// Network status placeholders are played as is if the status is not available in this time.
+var NetworkStatusTimeout = 5 * time.Second
+
+// If playback reaches a network status placeholder before the status is available, playback is held until this
+// long after the request. 0 disables waiting.
+var NetworkStatusWait time.Duration
+
+// If true, only the subscriptions on the timeslot reported by the device are announced.
+var NetworkTimeslotFilter = false
+
+var NetworkServerListURL = "http://x.sharkrf.com/db/homebrew/servers.json"
+
+// Static server list file in the remote server list's format. Its servers are added to the remote list, replacing
+// remote servers with the same host.
+var NetworkServerListPath string
+
+// The resolved server map is saved here after each successful update and loaded at startup.
+var NetworkServerListCachePath string
+
+// The contents of the server list cache file as last loaded or saved, so unchanged lists are not written again.
+var networkServerListCacheData []byte
+var networkServerListCacheMutex = &sync.Mutex{}
+
+var NetworkServerListRefreshInterval = time.Hour
+
+// Failed server list updates are retried after this, doubling on each failure up to the refresh interval.
+const networkServerListMinRetryInterval = 10 * time.Second
+
+// Used for all network API HTTP requests. Can be replaced to use a custom transport.
+var NetworkHTTPClient = &http.Client{Timeout: 2000 * time.Millisecond}
+
+var networkStatusProviders = make(map[string]NetworkStatusProvider)
+
+// A server list entry with its resolved addresses. The addresses are kept if re-resolving the host fails.
+type networkServerHost struct {
+	server  networkServerData
+	addrs   []string
+	expires time.Time
+}
+
+// Server hosts are checked for expired addresses this often.
+const networkServerHostsCheckInterval = 10 * time.Second
+
+// Both maps are guarded by networkServerIPHostsMutex, networkServerIPHosts is rebuilt from networkServerHosts.
+var networkServerHosts = make(map[string]*networkServerHost)
+var networkServerIPHosts = make(map[networkServerIP]networkServerData)
+var networkServerIPHostsMutex = &sync.Mutex{}
+
+func getJson(url string, target interface{}) error {
+	r, err := NetworkHTTPClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("http status %s", r.Status)
+	}
+
+	return json.NewDecoder(r.Body).Decode(target)
+}
+
+// NetworkRegisterStatusProvider registers the provider for the networks it handles.
+func NetworkRegisterStatusProvider(provider NetworkStatusProvider) {
+	for _, network := range provider.Networks() {
+		networkStatusProviders[network] = provider
+	}
+}
+
+// Returns url with {id} replaced by the client ID and {host} by the server's host.
+func networkExpandURLTemplate(url string, clientId uint32, sd *networkServerData) string {
+	url = strings.ReplaceAll(url, "{id}", fmt.Sprint(clientId))
+	return strings.ReplaceAll(url, "{host}", sd.Host)
+}
+
+func NetworkGetStatusProvider(network string) (NetworkStatusProvider, bool) {
+	provider, ok := networkStatusProviders[network]
+	return provider, ok
+}
+
+// Returns the server IP of connect announcements, which is stored in the first announce type data field.
+func networkGetServerIPForRequest(req *spkRequest) string {
+	return fmt.Sprintf("%d.%d.%d.%d", req.AnnounceTypeData[0]>>24, (req.AnnounceTypeData[0]>>16)&0xff,
+		(req.AnnounceTypeData[0]>>8)&0xff, req.AnnounceTypeData[0]&0xff)
+}
+
+// Returns the status provider and server data for a request, or false if the request's status can't be queried.
+func NetworkGetStatusProviderForRequest(req *spkRequest) (NetworkStatusProvider, networkServerData, bool) {
+	if req.AnnounceType != SPK_ANNOUNCE_TYPE_CONNECTED && req.AnnounceType != SPK_ANNOUNCE_TYPE_CONNECTED_BRANDMEISTER_SHORTENED &&
+		req.AnnounceType != SPK_ANNOUNCE_TYPE_CONNECTOR_STATUS {
+		return nil, networkServerData{}, false
+	}
+
+	serverIP := networkGetServerIPForRequest(req)
+
+	switch req.ConnectorID {
+	case SPK_CONNECTOR_ID_HOMEBREW:
+		// Homebrew servers can belong to any network, the server list tells which one.
+		if sd, ok := NetworkGetServerDataForServerIP(serverIP); ok {
+			if provider, ok := NetworkGetStatusProvider(sd.Network); ok {
+				return provider, sd, true
+			}
+		}
+	case SPK_CONNECTOR_ID_DMRPLUS:
+		if provider, ok := NetworkGetStatusProvider("DMR+"); ok {
+			// DMR+ masters are not necessarily in the server list, the master status endpoint is queried by IP.
+			sd, ok := NetworkGetServerDataForServerIP(serverIP)
+			if !ok || sd.Network != "DMR+" {
+				sd = networkServerData{Network: "DMR+", Host: serverIP}
+			}
+			return provider, sd, true
+		}
+	}
+	return nil, networkServerData{}, false
+}
+
+func NetworkGetClientData(provider NetworkStatusProvider, clientId uint32, sd networkServerData) (networkClientData, error) {
+	return networkCacheGetClientData(sd.Network, clientId, func(clientId uint32) (networkClientData, error) {
+		return provider.FetchClientData(clientId, &sd)
+	})
+}
+
+// Resolves the network status placeholders by querying the client's status from the server network's HTTP API.
+func NetworkResolveStatusPlaceholder(req *spkRequest, hasCodePair func(codePair string) bool) (string, bool) {
+	provider, sd, ok := NetworkGetStatusProviderForRequest(req)
+	if !ok {
+		return "", false
+	}
+
+	clientId := req.AnnounceTypeData[1]
+	log.Printf("getting %s client data srv:%s cid:%d", sd.Network, sd.Host, clientId)
+	cd, err := NetworkGetClientData(provider, clientId, sd)
+	if err != nil {
+		log.Println("getjson error: ", err)
+		return "", false
+	}
+	return NetworkGenerateCodeStrFromClientData(provider, &cd, &sd, req.AnnounceType == SPK_ANNOUNCE_TYPE_CONNECTED_BRANDMEISTER_SHORTENED,
+		req.Timeslot, hasCodePair), true
+}
+
+// Returns the talkgroup's short name from the tg db if the voice has all its code pairs, otherwise the
+// talkgroup number.
+func networkGenerateCodeStrForTalkgroup(tg string, hasCodePair func(codePair string) bool) string {
+	if entry, ok := TGDBGetEntry(tg); ok && codeStrIsAvailable(entry.Code, hasCodePair) {
+		return entry.Code
+	}
+	return codeStrForNumber(tg)
+}
+
+// Generates "linked <type> talkgroup(s) ..." for the given subscriptions. Long lists are shortened to
+// "linked <count> <type> talkgroups".
+func networkGenerateCodeStrForSubscriptions(subs []networkSubscription, typeCodeStr string, hasCodePair func(codePair string) bool) string {
+	if len(subs) == 0 {
+		return ""
+	}
+	if NetworkMaxListedTalkgroups > 0 && len(subs) > NetworkMaxListedTalkgroups {
+		return "LK" + codeStrForCount(len(subs)) + typeCodeStr + "GS"
+	}
+
+	var res string
+	if len(subs) == 1 {
+		res = "LK" + typeCodeStr + "TG"
+	} else {
+		res = "LK" + typeCodeStr + "GS"
+	}
+	for i := 0; i < len(subs); i++ {
+		if i > 0 {
+			res += "ND"
+		}
+		res += networkGenerateCodeStrForTalkgroup(subs[i].Talkgroup, hasCodePair)
+	}
+	return res
+}
+
+// Generates the subscription list for each timeslot, like "slot one linked static talkgroup 91". If timeslot is
+// not 0 and timeslot filtering is enabled, only subscriptions on the given timeslot are listed.
+func networkGenerateCodeStrForTimeslots(subs []networkSubscription, typeCodeStr string, timeslot uint8, hasCodePair func(codePair string) bool) string {
+	var res string
+
+	// Simplex subscriptions are in slot 0.
+	for slot := uint8(0); slot <= 2; slot++ {
+		if NetworkTimeslotFilter && timeslot != 0 && slot != 0 && slot != timeslot {
+			continue
+		}
+
+		var slotSubs []networkSubscription
+		for _, sub := range subs {
+			if sub.Slot == slot {
+				slotSubs = append(slotSubs, sub)
+			}
+		}
+		if len(slotSubs) == 0 {
+			continue
+		}
+
+		// Voices made before the slot phrase list the talkgroups without slots.
+		if slot != 0 && hasCodePair("TS") {
+			res += "TS" + codeStrForCount(int(slot))
+		}
+		res += networkGenerateCodeStrForSubscriptions(slotSubs, typeCodeStr, hasCodePair)
+	}
+	return res
+}
+
+// Generates the status announcement: network name, server ID and the client's status. hasCodePair tells if the
+// voice used for playing has a code pair, it's used to decide if talkgroup names can be spoken. timeslot is the
+// device's timeslot, or 0 if it's unknown.
+func NetworkGenerateCodeStrFromClientData(provider NetworkStatusProvider, cd *networkClientData, sd *networkServerData, shortened bool,
+	timeslot uint8, hasCodePair func(codePair string) bool) string {
+
+	var networkIDStr string
+	var statusStr string
+
+	if cd.ServerName != "" {
+		networkIDStr = codeStrForText(cd.ServerName, false)
+	} else if lastIndex := strings.LastIndex(sd.Name, "/"); lastIndex >= 0 {
+		networkIDStr = codeStrForDigits(sd.Name[lastIndex+1:])
+	}
+
+	// The device being blocked is announced even in shortened announcements, as nothing will work.
+	if cd.Device.Blocked && hasCodePair("BK") {
+		statusStr += "BK"
+	}
+
+	if !shortened {
+		statusStr += networkGenerateCodeStrForTimeslots(cd.StaticSubscriptions, "ST", timeslot, hasCodePair)
+		statusStr += networkGenerateCodeStrForTimeslots(cd.DynamicSubscriptions, "DN", timeslot, hasCodePair)
+
+		if len(cd.ReflectorSubscriptions) > 0 {
+			statusStr += "LKRF" + codeStrForNumber(cd.ReflectorSubscriptions[0].Talkgroup)
+		}
+		if cd.Device.CallRouting {
+			statusStr += "RE"
+		}
+		// Voices made before the last heard phrase skip it, as the talkgroup alone would be misleading.
+		if cd.Device.LastHeardTalkgroup != "" && hasCodePair("LH") {
+			statusStr += "LHTG" + networkGenerateCodeStrForTalkgroup(cd.Device.LastHeardTalkgroup, hasCodePair)
+		}
+	}
+
+	return provider.GenerateNameCodeStr(hasCodePair) + networkIDStr + statusStr
+}
+
+func NetworkGetServerDataForServerIP(addr string) (networkServerData, bool) {
+	networkServerIPHostsMutex.Lock()
+	defer networkServerIPHostsMutex.Unlock()
+	val, ok := networkServerIPHosts[networkServerIP(addr)]
+	return val, ok
+}
+
+func NetworkServerListPopulated() bool {
+	networkServerIPHostsMutex.Lock()
+	defer networkServerIPHostsMutex.Unlock()
+	return len(networkServerIPHosts) > 0
+}
+
+// Reads a server list file in the same format as the remote server list.
+func networkLoadServerListFile(path string) ([]networkServerData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var servers []networkServerData
+	err = json.NewDecoder(f).Decode(&servers)
+	return servers, err
+}
+
+// Adds the static servers to the list. Static servers replace the servers with the same host.
+func networkMergeServerLists(servers []networkServerData, static []networkServerData) []networkServerData {
+	staticHosts := make(map[string]bool)
+	for _, server := range static {
+		staticHosts[server.Host] = true
+	}
+
+	var res []networkServerData
+	for _, server := range servers {
+		if !staticHosts[server.Host] {
+			res = append(res, server)
+		}
+	}
+	return append(res, static...)
+}
+
+// Loads the resolved server map saved by the last update, so servers are known before the first update. The loaded
+// addresses are used until the hosts are re-resolved.
+func NetworkLoadServerListCache() {
+	data, err := os.ReadFile(NetworkServerListCachePath)
+	if err != nil {
+		log.Println("network server list cache load error: ", err)
+		return
+	}
+
+	var newList map[networkServerIP]networkServerData
+	if err := json.Unmarshal(data, &newList); err != nil {
+		log.Println("network server list cache load error: ", err)
+		return
+	}
+
+	networkServerListCacheMutex.Lock()
+	networkServerListCacheData = data
+	networkServerListCacheMutex.Unlock()
+
+	networkServerIPHostsMutex.Lock()
+	for addr, server := range newList {
+		sh, ok := networkServerHosts[server.Host]
+		if !ok {
+			sh = &networkServerHost{server: server}
+			networkServerHosts[server.Host] = sh
+		}
+		sh.addrs = append(sh.addrs, string(addr))
+	}
+	networkRebuildServerIPHosts()
+	networkServerIPHostsMutex.Unlock()
+	log.Printf("loaded %d network server addresses from %s\n", len(newList), NetworkServerListCachePath)
+}
+
+func networkSaveServerListCache(list map[networkServerIP]networkServerData) error {
+	// Map keys are sorted by json.Marshal, so the same list always gives the same data.
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	networkServerListCacheMutex.Lock()
+	defer networkServerListCacheMutex.Unlock()
+	if bytes.Equal(data, networkServerListCacheData) {
+		return nil
+	}
+
+	// Writing to a temp file first, so a crash while saving won't leave a broken cache behind.
+	tmpPath := NetworkServerListCachePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, NetworkServerListCachePath); err != nil {
+		return err
+	}
+	networkServerListCacheData = data
+	return nil
+}
+
+// Rebuilds the IP to server data map from the server hosts. networkServerIPHostsMutex must be locked.
+func networkRebuildServerIPHosts() {
+	newList := make(map[networkServerIP]networkServerData)
+	for _, sh := range networkServerHosts {
+		// Storing all addresses so we can get server data for an IP later.
+		for _, addr := range sh.addrs {
+			newList[networkServerIP(addr)] = sh.server
+		}
+	}
+	networkServerIPHosts = newList
+}
+
+// Re-resolves the server hosts with expired addresses. Hosts which fail to resolve keep their last good addresses.
+func networkResolveServerHosts() {
+	now := time.Now()
+	var hosts []string
+
+	networkServerIPHostsMutex.Lock()
+	for host, sh := range networkServerHosts {
+		if !now.Before(sh.expires) {
+			hosts = append(hosts, host)
+		}
+	}
+	networkServerIPHostsMutex.Unlock()
+
+	if len(hosts) == 0 {
+		return
+	}
+
+	for _, host := range hosts {
+		addrs, ttl, err := DNSLookupHost(host)
+
+		networkServerIPHostsMutex.Lock()
+		// The host may have been removed by a server list update in the meantime.
+		if sh, ok := networkServerHosts[host]; ok {
+			if err != nil {
+				log.Printf("resolving network server %s error, keeping %d addresses: %v\n", host, len(sh.addrs), err)
+				sh.expires = time.Now().Add(DNSMinTTL)
+			} else {
+				sh.addrs = addrs
+				sh.expires = time.Now().Add(ttl)
+			}
+		}
+		networkServerIPHostsMutex.Unlock()
+	}
+
+	networkServerIPHostsMutex.Lock()
+	networkRebuildServerIPHosts()
+	newList := networkServerIPHosts
+	networkServerIPHostsMutex.Unlock()
+
+	if NetworkServerListCachePath != "" && len(newList) > 0 {
+		if err := networkSaveServerListCache(newList); err != nil {
+			log.Println("network server list cache save error: ", err)
+		}
+	}
+}
+
+// Returns false if the update failed and should be retried.
+func NetworkUpdateServerList() bool {
+	// The list loaded from the cache is kept.
+	if NetworkServerListURL == "" && NetworkServerListPath == "" {
+		log.Println("no network server list url or file, keeping the current list")
+		return true
+	}
+
+	log.Println("updating network server list")
+
+	var servers []networkServerData
+	if NetworkServerListURL != "" {
+		err := getJson(NetworkServerListURL, &servers)
+		if err != nil {
+			log.Println("update network server list getjson error: ", err)
+			return false
+		}
+		if len(servers) == 0 {
+			log.Println("update network server list error: empty list")
+			return false
+		}
+	}
+
+	if NetworkServerListPath != "" {
+		static, err := networkLoadServerListFile(NetworkServerListPath)
+		if err != nil {
+			log.Println("update network server list static file error: ", err)
+			return false
+		}
+		servers = networkMergeServerLists(servers, static)
+	}
+
+	networkServerIPHostsMutex.Lock()
+	newHosts := make(map[string]*networkServerHost)
+	for _, server := range servers {
+		// Only servers of networks with a status provider are interesting.
+		if _, ok := NetworkGetStatusProvider(server.Network); !ok {
+			continue
+		}
+
+		// Already known hosts keep their addresses until they expire, new hosts are resolved right away.
+		sh, ok := networkServerHosts[server.Host]
+		if !ok {
+			sh = &networkServerHost{}
+		}
+		sh.server = server
+		newHosts[server.Host] = sh
+	}
+	networkServerHosts = newHosts
+	networkRebuildServerIPHosts()
+	networkServerIPHostsMutex.Unlock()
+
+	networkResolveServerHosts()
+	log.Println("updating network server list finished")
+	return true
+}
+
+// Re-resolves server hosts when their addresses expire.
+func NetworkResolveProcess() {
+	for {
+		time.Sleep(networkServerHostsCheckInterval)
+		networkResolveServerHosts()
+	}
+}
+
+func NetworkProcess() {
+	if NetworkServerListCachePath != "" {
+		NetworkLoadServerListCache()
+	}
+
+	retryInterval := networkServerListMinRetryInterval
+	for {
+		if NetworkUpdateServerList() {
+			retryInterval = networkServerListMinRetryInterval
+			time.Sleep(NetworkServerListRefreshInterval)
+			continue
+		}
+
+		log.Printf("retrying network server list update in %v\n", retryInterval)
+		time.Sleep(retryInterval)
+		retryInterval = min(retryInterval*2, NetworkServerListRefreshInterval)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func hasAllCodePairs(codePair string) bool { return true }
+
+func hasCodePairsExcept(missing ...string) func(codePair string) bool {
+	return func(codePair string) bool {
+		for _, m := range missing {
+			if codePair == m {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+type networkProviderTest struct {
+	name string
+	// Served JSON. Empty for an http error.
+	response    string
+	want        networkClientData
+	wantCodeStr string
+	wantErr     bool
+}
+
+// Runs the provider against a test API server. The provider's URL template is set to the server's URL and
+// urlTemplate, and the requested path must be wantPath.
+func networkTestProvider(t *testing.T, provider NetworkStatusProvider, apiURL *string, urlTemplate string, wantPath string,
+	sd networkServerData, hasCodePair func(codePair string) bool, tests []networkProviderTest) {
+
+	saved := *apiURL
+	t.Cleanup(func() { *apiURL = saved })
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != wantPath {
+				t.Errorf("%s: requested %s, want %s", tt.name, r.URL.Path, wantPath)
+			}
+			if tt.response == "" {
+				http.Error(w, "error", http.StatusInternalServerError)
+				return
+			}
+			fmt.Fprint(w, tt.response)
+		}))
+		*apiURL = server.URL + urlTemplate
+
+		got, err := provider.FetchClientData(2161234, &sd)
+		server.Close()
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, %v, want %+v, error %v", tt.name, got, err, tt.want, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if codeStr := NetworkGenerateCodeStrFromClientData(provider, &got, &sd, false, 0, hasCodePair); codeStr != tt.wantCodeStr {
+			t.Errorf("%s: got code str %q, want %q", tt.name, codeStr, tt.wantCodeStr)
+		}
+	}
+}
+
+func TestNetworkGenerateCodeStrForTimeslots(t *testing.T) {
+	subs := []networkSubscription{{"91", 1}, {"2161", 2}, {"216", 2}}
+	simplex := []networkSubscription{{"91", 0}}
+
+	tests := []struct {
+		name        string
+		subs        []networkSubscription
+		hasCodePair func(codePair string) bool
+		want        string
+	}{
+		{"slots", subs, hasAllCodePairs, "TS01LKSTTG#9#1TS02LKSTGS#2#1#6#1ND#2#1#6"},
+		{"no slot phrase", subs, hasCodePairsExcept("TS"), "LKSTTG#9#1LKSTGS#2#1#6#1ND#2#1#6"},
+		{"simplex", simplex, hasAllCodePairs, "LKSTTG#9#1"},
+		{"none", nil, hasAllCodePairs, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := networkGenerateCodeStrForTimeslots(tt.subs, "ST", 0, tt.hasCodePair); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetworkSaveServerListCache(t *testing.T) {
+	savedPath := NetworkServerListCachePath
+	defer func() {
+		NetworkServerListCachePath = savedPath
+		networkServerListCacheData = nil
+	}()
+	NetworkServerListCachePath = filepath.Join(t.TempDir(), "servers.json")
+
+	list := map[networkServerIP]networkServerData{"10.0.0.1": {"BrandMeister", "2161", "hu.example.org"}}
+	if err := networkSaveServerListCache(list); err != nil {
+		t.Fatal(err)
+	}
+	// The file is not written again if the list has not changed.
+	if err := os.Remove(NetworkServerListCachePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := networkSaveServerListCache(list); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(NetworkServerListCachePath); !os.IsNotExist(err) {
+		t.Error("unchanged list has been written")
+	}
+
+	list["10.0.0.2"] = networkServerData{"BrandMeister", "2162", "hu2.example.org"}
+	if err := networkSaveServerListCache(list); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(NetworkServerListCachePath); err != nil {
+		t.Error("changed list has not been written: ", err)
+	}
+}
+
+func TestNetworkUpdateServerListWithoutSource(t *testing.T) {
+	savedURL, savedPath := NetworkServerListURL, NetworkServerListPath
+	savedHosts, savedIPHosts := networkServerHosts, networkServerIPHosts
+	defer func() {
+		NetworkServerListURL, NetworkServerListPath = savedURL, savedPath
+		networkServerIPHostsMutex.Lock()
+		networkServerHosts, networkServerIPHosts = savedHosts, savedIPHosts
+		networkServerIPHostsMutex.Unlock()
+	}()
+	NetworkServerListURL, NetworkServerListPath = "", ""
+
+	// Hosts loaded from the cache.
+	server := networkServerData{"BrandMeister", "2161", "hu.example.org"}
+	networkServerIPHostsMutex.Lock()
+	networkServerHosts = map[string]*networkServerHost{server.Host: {server: server, addrs: []string{"10.0.0.1"}}}
+	networkRebuildServerIPHosts()
+	networkServerIPHostsMutex.Unlock()
+
+	if !NetworkUpdateServerList() {
+		t.Fatal("update failed")
+	}
+	if got, ok := NetworkGetServerDataForServerIP("10.0.0.1"); !ok || got != server {
+		t.Errorf("cached server lost, got %+v, %v", got, ok)
+	}
+}
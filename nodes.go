@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An EchoLink or AllStarLink node list entry. IP is optional, it's used to find the node if the device doesn't
+// report the node number.
+type nodeEntry struct {
+	Number   string
+	Callsign string
+	IP       string
+}
+
+type nodeList struct {
+	ConnectorID spkConnectorId
+	Path        string
+	watch       fileWatch
+	byNumber    map[string]nodeEntry
+	byIP        map[string]nodeEntry
+}
+
+var NodeLists = []*nodeList{
+	{ConnectorID: SPK_CONNECTOR_ID_ECHOLINK},
+	{ConnectorID: SPK_CONNECTOR_ID_IAX2},
+}
+
+var NodesRefreshInterval = 10 * time.Minute
+
+var nodeListsMutex = &sync.Mutex{}
+
+// Sets the path of the node list for the given connector.
+func NodesSetPath(connectorID spkConnectorId, path string) {
+	for _, l := range NodeLists {
+		if l.ConnectorID == connectorID {
+			l.Path = path
+		}
+	}
+}
+
+// Parses a node list in the AllStarLink astdb.txt format: node|callsign|description|location, with an optional
+// fifth IP field. Lines starting with # are ignored.
+func nodesLoad(path string) (map[string]nodeEntry, map[string]nodeEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	byNumber := make(map[string]nodeEntry)
+	byIP := make(map[string]nodeEntry)
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "|")
+		if len(fields) < 2 {
+			return nil, nil, fmt.Errorf("line %d: too few fields", lineNum)
+		}
+
+		entry := nodeEntry{Number: strings.TrimSpace(fields[0]), Callsign: strings.TrimSpace(fields[1])}
+		if len(fields) > 4 {
+			entry.IP = strings.TrimSpace(fields[4])
+		}
+		byNumber[entry.Number] = entry
+		if entry.IP != "" {
+			byIP[entry.IP] = entry
+		}
+	}
+	return byNumber, byIP, scanner.Err()
+}
+
+// Reloads the node lists which have been modified since the last load.
+func NodesUpdate() {
+	for _, l := range NodeLists {
+		if l.Path == "" {
+			continue
+		}
+
+		l.watch.update("node list", l.Path, func() error {
+			byNumber, byIP, err := nodesLoad(l.Path)
+			if err != nil {
+				return err
+			}
+
+			nodeListsMutex.Lock()
+			l.byNumber = byNumber
+			l.byIP = byIP
+			nodeListsMutex.Unlock()
+			log.Printf("loaded %d nodes\n", len(byNumber))
+			return nil
+		})
+	}
+}
+
+// Returns the node the device is connecting to. The node number is taken from the second announce type data field,
+// if it's 0, the node is looked up by the server IP.
+func NodesGetForRequest(req *spkRequest) (nodeEntry, bool) {
+	if req.AnnounceType != SPK_ANNOUNCE_TYPE_CONNECTED && req.AnnounceType != SPK_ANNOUNCE_TYPE_CONNECTOR_STATUS {
+		return nodeEntry{}, false
+	}
+
+	nodeListsMutex.Lock()
+	defer nodeListsMutex.Unlock()
+
+	for _, l := range NodeLists {
+		// Without a node list the firmware's announcement is kept.
+		if l.ConnectorID != req.ConnectorID || l.Path == "" {
+			continue
+		}
+
+		if req.AnnounceTypeData[1] != 0 {
+			number := fmt.Sprint(req.AnnounceTypeData[1])
+			if entry, ok := l.byNumber[number]; ok {
+				return entry, true
+			}
+			// Still announcing the node number if it's not in the list.
+			return nodeEntry{Number: number}, true
+		}
+		entry, ok := l.byIP[networkGetServerIPForRequest(req)]
+		return entry, ok
+	}
+	return nodeEntry{}, false
+}
+
+// Returns "echolink node 123456 <phonetic callsign>", or "allstarlink node ..." for IAX2 connections.
+func NodesGenerateCodeStr(connectorID spkConnectorId, entry *nodeEntry) string {
+	res := "EL"
+	if connectorID == SPK_CONNECTOR_ID_IAX2 {
+		res = "HS"
+	}
+	res += "NO" + codeStrForDigits(entry.Number)
+	if entry.Callsign != "" {
+		res += codeStrForText(entry.Callsign, true)
+	}
+	return res
+}
+
+// Resolves the node placeholder to the EchoLink or AllStarLink node the device is connecting to.
+func NodesResolvePlaceholder(req *spkRequest, hasCodePair func(codePair string) bool) (string, bool) {
+	if entry, ok := NodesGetForRequest(req); ok {
+		return NodesGenerateCodeStr(req.ConnectorID, &entry), true
+	}
+	return "", false
+}
+
+// NodesApply replaces the node the firmware sent after "connected to" with the one from the node list, unless the
+// code str has the node placeholder. Returns true if the request has been changed.
+func NodesApply(req *spkRequest) bool {
+	if placeholderIndex(req.CodeStr, "NOSV") >= 0 {
+		return false
+	}
+	entry, ok := NodesGetForRequest(req)
+	if !ok {
+		return false
+	}
+	codeStr, ok := codeStrReplaceConnectedTarget(req.CodeStr, NodesGenerateCodeStr(req.ConnectorID, &entry))
+	req.CodeStr = codeStr
+	return ok
+}
+
+func NodesProcess() {
+	for {
+		NodesUpdate()
+		time.Sleep(NodesRefreshInterval)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNodesLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "astdb.txt")
+	data := "# comment\n\n2000|W1AW|ARRL|Newington, CT|10.0.0.1\n123456|HA2NON|Test|Budapest\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	byNumber, byIP, err := nodesLoad(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byNumber) != 2 || byNumber["123456"] != (nodeEntry{"123456", "HA2NON", ""}) {
+		t.Errorf("byNumber = %+v", byNumber)
+	}
+	if len(byIP) != 1 || byIP["10.0.0.1"] != (nodeEntry{"2000", "W1AW", "10.0.0.1"}) {
+		t.Errorf("byIP = %+v", byIP)
+	}
+
+	if err := os.WriteFile(path, []byte("2000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := nodesLoad(path); err == nil {
+		t.Error("expected an error for a line without callsign")
+	}
+}
+
+func TestNodesGenerateCodeStr(t *testing.T) {
+	tests := []struct {
+		connectorID spkConnectorId
+		entry       nodeEntry
+		want        string
+	}{
+		{SPK_CONNECTOR_ID_ECHOLINK, nodeEntry{Number: "123456", Callsign: "HA2NON"}, "ELNO010203040506PHPA02PNPOPN"},
+		{SPK_CONNECTOR_ID_IAX2, nodeEntry{Number: "2000"}, "HSNO02000000"},
+	}
+	for _, tt := range tests {
+		if got := NodesGenerateCodeStr(tt.connectorID, &tt.entry); got != tt.want {
+			t.Errorf("NodesGenerateCodeStr(%+v) = %q, want %q", tt.entry, got, tt.want)
+		}
+	}
+}
+
+func TestNodesApply(t *testing.T) {
+	saved := NodeLists
+	defer func() { NodeLists = saved }()
+
+	withList := &nodeList{ConnectorID: SPK_CONNECTOR_ID_IAX2, Path: "astdb.txt",
+		byNumber: map[string]nodeEntry{"2000": {"2000", "W1AW", "10.0.0.1"}},
+		byIP:     map[string]nodeEntry{"10.0.0.1": {"2000", "W1AW", "10.0.0.1"}}}
+	withoutList := &nodeList{ConnectorID: SPK_CONNECTOR_ID_ECHOLINK}
+	NodeLists = []*nodeList{withList, withoutList}
+
+	tests := []struct {
+		name        string
+		connectorID spkConnectorId
+		data        [2]uint32
+		codeStr     string
+		want        string
+		applied     bool
+	}{
+		{"by number", SPK_CONNECTOR_ID_IAX2, [2]uint32{0, 2000}, "CTHSNO0200", "CTHSNO02000000PW01PAPW", true},
+		{"by ip", SPK_CONNECTOR_ID_IAX2, [2]uint32{0x0a000001, 0}, "CTHS", "CTHSNO02000000PW01PAPW", true},
+		{"unlisted number", SPK_CONNECTOR_ID_IAX2, [2]uint32{0, 2001}, "CTHS", "CTHSNO02000001", true},
+		{"unknown ip", SPK_CONNECTOR_ID_IAX2, [2]uint32{0x0a000002, 0}, "CTHS", "CTHS", false},
+		{"rest kept", SPK_CONNECTOR_ID_IAX2, [2]uint32{0, 2000}, "CTHSNO0200PWVE", "CTHSNO02000000PW01PAPWVE", true},
+		{"no connected to", SPK_CONNECTOR_ID_IAX2, [2]uint32{0, 2000}, "HSNO0200", "HSNO0200", false},
+		{"placeholder", SPK_CONNECTOR_ID_IAX2, [2]uint32{0, 2000}, "CTNOSV", "CTNOSV", false},
+		{"placeholder not on a code pair boundary", SPK_CONNECTOR_ID_IAX2, [2]uint32{0, 2000}, "CTANOSV0",
+			"CTHSNO02000000PW01PAPWOSV0", true},
+		{"no list", SPK_CONNECTOR_ID_ECHOLINK, [2]uint32{0, 123456}, "CTEL", "CTEL", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := spkRequest{ConnectorID: tt.connectorID, AnnounceType: SPK_ANNOUNCE_TYPE_CONNECTED,
+				AnnounceTypeData: tt.data, CodeStr: tt.codeStr}
+			if applied := NodesApply(&req); applied != tt.applied || req.CodeStr != tt.want {
+				t.Errorf("got %q, %v, want %q, %v", req.CodeStr, applied, tt.want, tt.applied)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Numbers in code strs are written as "#" and digit pairs, like "#9#1" for 91, and are rendered to code pairs
+// with the number grammar of the voice when played.
+const numberMarkupChar = '#'
+
+// Describes how a voice pack's language says numbers. The zero value spells numbers digit by digit.
+type numberGrammar struct {
+	// "digits" spells numbers digit by digit, "words" says them like "two thousand one hundred sixty one".
+	Style string `json:"style,omitempty"`
+	// Bigger numbers and numbers with leading zeros are spelled digit by digit. 0 means 9999.
+	Max int `json:"max,omitempty"`
+	// Code pairs said after the hundreds and thousands.
+	Hundred  string `json:"hundred,omitempty"`
+	Thousand string `json:"thousand,omitempty"`
+	// Code pair said before hundred and thousand for one of them, like "01" for "one hundred". Empty says just
+	// "hundred".
+	One string `json:"one,omitempty"`
+	// Code str inserted between the hundreds and the rest, like "and" in British English.
+	And string `json:"and,omitempty"`
+	// If set, numbers between 21 and 99 are composed from the tens and units instead of using their own code pair.
+	Compose bool `json:"compose,omitempty"`
+	// Says the units before the tens, like "einundzwanzig" in German.
+	UnitsFirst bool `json:"unitsFirst,omitempty"`
+	// Code str inserted between the tens and units of composed numbers, like "und" in German.
+	Joiner string `json:"joiner,omitempty"`
+	// Code pairs used instead of the tens followed by units, like "huszon" for 20 in Hungarian.
+	CombiningTens map[string]string `json:"combiningTens,omitempty"`
+}
+
+// Returns the code str markup for a number, rendered by the voice's number grammar. Digits can have leading zeros.
+func codeStrForNumber(digits string) string {
+	var res string
+	for i := 0; i < len(digits); i++ {
+		res += string(numberMarkupChar) + string(digits[i])
+	}
+	return res
+}
+
+// Returns the digits of the number markup at the start of codeStr, empty if there's none.
+func numberParseMarkup(codeStr string) string {
+	var res strings.Builder
+	for i := 0; i+1 < len(codeStr) && codeStr[i] == numberMarkupChar && codeStr[i+1] >= '0' && codeStr[i+1] <= '9'; i += 2 {
+		res.WriteByte(codeStr[i+1])
+	}
+	return res.String()
+}
+
+// Returns the code str saying the number.
+func (g *numberGrammar) codeStrForNumber(digits string) string {
+	max := g.Max
+	if max == 0 {
+		max = 9999
+	}
+	n, err := strconv.Atoi(digits)
+	if g.Style != "words" || err != nil || n < 0 || n > max || (len(digits) > 1 && digits[0] == '0') {
+		return codeStrForDigits(digits)
+	}
+	return g.words(n)
+}
+
+func (g *numberGrammar) words(n int) string {
+	var res string
+	if n >= 1000 {
+		res += g.multiplier(n/1000) + g.Thousand
+		n %= 1000
+	}
+	if n >= 100 {
+		res += g.multiplier(n/100) + g.Hundred
+		n %= 100
+	}
+	if n > 0 || res == "" {
+		if res != "" {
+			res += g.And
+		}
+		res += g.belowHundred(n)
+	}
+	return res
+}
+
+func (g *numberGrammar) multiplier(n int) string {
+	if n == 1 {
+		return g.One
+	}
+	return g.words(n)
+}
+
+func (g *numberGrammar) belowHundred(n int) string {
+	if !g.Compose || n < 20 || n%10 == 0 {
+		return codeStrForCount(n)
+	}
+
+	tens := codeStrForCount(n / 10 * 10)
+	if combining, ok := g.CombiningTens[tens]; ok {
+		tens = combining
+	}
+	if g.UnitsFirst {
+		return codeStrForCount(n%10) + g.Joiner + tens
+	}
+	return tens + g.Joiner + codeStrForCount(n%10)
+}
@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+func TestNumberMarkup(t *testing.T) {
+	if got := codeStrForNumber("0216"); got != "#0#2#1#6" {
+		t.Errorf("codeStrForNumber(\"0216\") = %q", got)
+	}
+
+	tests := []struct {
+		codeStr string
+		want    string
+	}{
+		{"#2#1CT", "21"},
+		{"#9", "9"},
+		{"#2#", "2"},
+		{"#a#1", ""},
+		{"CT#2", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := numberParseMarkup(tt.codeStr); got != tt.want {
+			t.Errorf("numberParseMarkup(%q) = %q, want %q", tt.codeStr, got, tt.want)
+		}
+	}
+}
+
+func TestNumberGrammar(t *testing.T) {
+	english := numberGrammar{Style: "words", Hundred: "HU", Thousand: "TH", One: "01"}
+	british := numberGrammar{Style: "words", Hundred: "HU", Thousand: "TH", One: "01", And: "AN"}
+	german := numberGrammar{Style: "words", Hundred: "HU", Thousand: "TH", Compose: true, UnitsFirst: true, Joiner: "UN"}
+	hungarian := numberGrammar{Style: "words", Hundred: "HU", Thousand: "TH", Compose: true,
+		CombiningTens: map[string]string{"10": "TZ", "20": "HZ"}}
+
+	tests := []struct {
+		name    string
+		grammar numberGrammar
+		digits  string
+		want    string
+	}{
+		{"digits", numberGrammar{}, "2161", "02010601"},
+		{"digits leading zero", numberGrammar{}, "09", "0009"},
+		{"zero", english, "0", "00"},
+		{"below hundred", english, "61", "61"},
+		{"hundred", english, "100", "01HU"},
+		{"thousands", english, "2161", "02TH01HU61"},
+		{"thousand and units", english, "1005", "01TH05"},
+		{"leading zero spelled", english, "0216", "00020106"},
+		{"above default max", english, "12345", "0102030405"},
+		{"above max", numberGrammar{Style: "words", Max: 999}, "2161", "02010601"},
+		{"and", british, "105", "01HUAN05"},
+		{"and without rest", british, "200", "02HU"},
+		{"units first", german, "21", "01UN20"},
+		{"composed tens", german, "30", "30"},
+		{"teens", german, "15", "15"},
+		{"no one", german, "121", "HU01UN20"},
+		{"combining tens", hungarian, "21", "HZ01"},
+		{"combining tens not listed", hungarian, "31", "3001"},
+		{"combining tens alone", hungarian, "20", "20"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.grammar.codeStrForNumber(tt.digits); got != tt.want {
+				t.Errorf("codeStrForNumber(%q) = %q, want %q", tt.digits, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderNumber(t *testing.T) {
+	p := &placeholderPending{Token: "TISV", Pos: 6}
+	s := &spkAnswerStream{codeStr: "TG#9#1TISV", codeStrPos: 2, placeholders: []*placeholderPending{p},
+		numbers: numberGrammar{Style: "words"}}
+
+	if !s.renderNumber() {
+		t.Fatal("no number rendered")
+	}
+	if s.codeStr != "TG91TISV" || p.Pos != 4 {
+		t.Errorf("got %q with the placeholder at %d", s.codeStr, p.Pos)
+	}
+	if s.renderNumber() {
+		t.Error("rendered number where there's none")
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Resolves a code str placeholder token to code pairs.
+type placeholderResolver struct {
+	// The token in the code str. It's only matched on code pair boundaries.
+	Token string
+	// Immediate resolvers don't block, they are run before streaming starts. Others are run in the background and
+	// their result is used when playback reaches the token.
+	Immediate bool
+	// If the resolver takes longer than this, the token is played as is. 0 means no timeout.
+	Timeout time.Duration
+	// If playback reaches the token before the result is available, the stream is held until this long after
+	// resolving started. 0 means the token is played as is without waiting.
+	Wait time.Duration
+	// Returns the code str replacing the token, or false if the token should be played as is.
+	Resolve func(req *spkRequest, hasCodePair func(codePair string) bool) (string, bool)
+}
+
+type placeholderStatus int
+
+const (
+	placeholderStatusPending placeholderStatus = iota
+	placeholderStatusResolved
+	placeholderStatusFailed
+	placeholderStatusTimedOut
+)
+
+func (st placeholderStatus) String() string {
+	switch st {
+	case placeholderStatusPending:
+		return "pending"
+	case placeholderStatusResolved:
+		return "resolved"
+	case placeholderStatusFailed:
+		return "failed"
+	case placeholderStatusTimedOut:
+		return "timed out"
+	}
+	return "unknown"
+}
+
+// CodeStr is the replacement if the token has been resolved, otherwise it's the token itself as fallback.
+type placeholderResult struct {
+	Status  placeholderStatus
+	CodeStr string
+}
+
+// A token found in a code str which is being resolved in the background.
+type placeholderPending struct {
+	Token   string
+	Pos     int
+	started time.Time
+	wait    time.Duration
+	result  chan placeholderResult
+}
+
+var placeholderResolvers = make(map[string]*placeholderResolver)
+
+// PlaceholderRegisterResolver registers the resolver for its token.
+func PlaceholderRegisterResolver(resolver *placeholderResolver) {
+	placeholderResolvers[resolver.Token] = resolver
+}
+
+func placeholderRun(resolver *placeholderResolver, req *spkRequest, hasCodePair func(codePair string) bool) chan placeholderResult {
+	// Buffered, so the resolver goroutine won't block if nobody waits for the result anymore.
+	done := make(chan placeholderResult, 1)
+	go func() {
+		if codeStr, ok := resolver.Resolve(req, hasCodePair); ok {
+			done <- placeholderResult{Status: placeholderStatusResolved, CodeStr: codeStr}
+		} else {
+			done <- placeholderResult{Status: placeholderStatusFailed, CodeStr: resolver.Token}
+		}
+	}()
+
+	if resolver.Timeout == 0 {
+		return done
+	}
+
+	result := make(chan placeholderResult, 1)
+	go func() {
+		select {
+		case res := <-done:
+			result <- res
+		case <-time.After(resolver.Timeout):
+			result <- placeholderResult{Status: placeholderStatusTimedOut, CodeStr: resolver.Token}
+		}
+	}()
+	return result
+}
+
+// Returns the position of the token in codeStr, or -1 if it's not there. Like placeholders, it's only matched on
+// code pair boundaries.
+func placeholderIndex(codeStr string, token string) int {
+	for pos := 0; pos+len(token) <= len(codeStr); pos += 2 {
+		if strings.HasPrefix(codeStr[pos:], token) {
+			return pos
+		}
+	}
+	return -1
+}
+
+// Replaces the tokens of immediate resolvers in codeStr, and starts resolving the other tokens in the background.
+// Only the given tokens are handled. Returns the new code str and the pending tokens in code str order.
+func PlaceholderStart(codeStr string, tokens []string, req *spkRequest, hasCodePair func(codePair string) bool) (string, []*placeholderPending) {
+	var pending []*placeholderPending
+
+	for pos := 0; pos+2 <= len(codeStr); pos += 2 {
+		for _, token := range tokens {
+			resolver, ok := placeholderResolvers[token]
+			if !ok || !strings.HasPrefix(codeStr[pos:], token) {
+				continue
+			}
+
+			if resolver.Immediate {
+				if res, ok := resolver.Resolve(req, hasCodePair); ok {
+					codeStr = codeStr[:pos] + res + codeStr[pos+len(token):]
+					// The replacement is not scanned for tokens.
+					pos += len(res) - 2
+				} else {
+					pos += len(token) - 2
+				}
+			} else {
+				pending = append(pending, &placeholderPending{Token: token, Pos: pos, started: time.Now(), wait: resolver.Wait,
+					result: placeholderRun(resolver, req, hasCodePair)})
+				pos += len(token) - 2
+			}
+			break
+		}
+	}
+	return codeStr, pending
+}
+
+// Gets the result of a pending token without blocking. If the result is not available yet and the wait time has
+// not elapsed, the returned status is pending. After the wait time, the token is played as is.
+func (p *placeholderPending) Result() placeholderResult {
+	select {
+	case res := <-p.result:
+		return res
+	default:
+		if time.Since(p.started) < p.wait {
+			return placeholderResult{Status: placeholderStatusPending}
+		}
+		return placeholderResult{Status: placeholderStatusTimedOut, CodeStr: p.Token}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlaceholderStart(t *testing.T) {
+	resolve := func(codeStr string, ok bool) func(*spkRequest, func(string) bool) (string, bool) {
+		return func(*spkRequest, func(string) bool) (string, bool) { return codeStr, ok }
+	}
+	PlaceholderRegisterResolver(&placeholderResolver{Token: "QASV", Immediate: true, Resolve: resolve("0102", true)})
+	PlaceholderRegisterResolver(&placeholderResolver{Token: "QBSV", Immediate: true, Resolve: resolve("", false)})
+	PlaceholderRegisterResolver(&placeholderResolver{Token: "QCSV", Resolve: resolve("03", true)})
+	// The replacement has a token, which should not be resolved again.
+	PlaceholderRegisterResolver(&placeholderResolver{Token: "QDSV", Immediate: true, Resolve: resolve("QASV", true)})
+
+	tests := []struct {
+		name    string
+		codeStr string
+		tokens  []string
+		want    string
+		pending []int
+	}{
+		{"immediate", "CTQASVND", []string{"QASV"}, "CT0102ND", nil},
+		{"failed immediate", "CTQBSV", []string{"QBSV"}, "CTQBSV", nil},
+		{"not on pair boundary", "CQASVN", []string{"QASV"}, "CQASVN", nil},
+		{"token not handled", "CTQASV", []string{"QCSV"}, "CTQASV", nil},
+		{"unknown token", "CTXXSV", []string{"XXSV"}, "CTXXSV", nil},
+		{"replacement not scanned", "QDSVQASV", []string{"QASV", "QDSV"}, "QASV0102", nil},
+		{"pending after replacement", "QASVCTQCSV", []string{"QASV", "QCSV"}, "0102CTQCSV", []int{6}},
+		{"two pending", "QCSVCTQCSV", []string{"QCSV"}, "QCSVCTQCSV", []int{0, 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, pending := PlaceholderStart(tt.codeStr, tt.tokens, &spkRequest{}, hasAllCodePairs)
+			if got != tt.want {
+				t.Errorf("code str %q, want %q", got, tt.want)
+			}
+			if len(pending) != len(tt.pending) {
+				t.Fatalf("%d pending, want %d", len(pending), len(tt.pending))
+			}
+			for i, p := range pending {
+				if p.Pos != tt.pending[i] {
+					t.Errorf("pending %d at %d, want %d", i, p.Pos, tt.pending[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPlaceholderResult(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	slow := func(*spkRequest, func(string) bool) (string, bool) {
+		<-block
+		return "01", true
+	}
+
+	tests := []struct {
+		name     string
+		resolver placeholderResolver
+		sleep    time.Duration
+		want     placeholderResult
+	}{
+		{"resolved", placeholderResolver{Token: "QESV", Resolve: func(*spkRequest, func(string) bool) (string, bool) {
+			return "0102", true
+		}}, 10 * time.Millisecond, placeholderResult{placeholderStatusResolved, "0102"}},
+		{"failed", placeholderResolver{Token: "QESV", Resolve: func(*spkRequest, func(string) bool) (string, bool) {
+			return "", false
+		}}, 10 * time.Millisecond, placeholderResult{placeholderStatusFailed, "QESV"}},
+		{"pending", placeholderResolver{Token: "QESV", Wait: time.Minute, Resolve: slow}, 0,
+			placeholderResult{Status: placeholderStatusPending}},
+		{"no wait", placeholderResolver{Token: "QESV", Resolve: slow}, 0, placeholderResult{placeholderStatusTimedOut, "QESV"}},
+		{"timeout", placeholderResolver{Token: "QESV", Timeout: time.Millisecond, Wait: time.Minute, Resolve: slow},
+			10 * time.Millisecond, placeholderResult{placeholderStatusTimedOut, "QESV"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			PlaceholderRegisterResolver(&tt.resolver)
+			_, pending := PlaceholderStart("QESV", []string{"QESV"}, &spkRequest{}, hasAllCodePairs)
+			if len(pending) != 1 {
+				t.Fatalf("%d pending, want 1", len(pending))
+			}
+			time.Sleep(tt.sleep)
+			if got := pending[0].Result(); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaceholderIndex(t *testing.T) {
+	tests := []struct {
+		codeStr string
+		token   string
+		want    int
+	}{
+		{"RFSV", "RFSV", 0},
+		{"CTRFSV", "RFSV", 2},
+		{"CTARFSV1", "RFSV", -1},
+		{"CTARFSV1RFSV", "RFSV", 8},
+		{"CTRFS", "RFSV", -1},
+		{"", "RFSV", -1},
+	}
+	for _, tt := range tests {
+		if got := placeholderIndex(tt.codeStr, tt.token); got != tt.want {
+			t.Errorf("placeholderIndex(%q, %q) = %d, want %d", tt.codeStr, tt.token, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Changes the announcement of a given announce type. If CodeStr is set, it replaces the requested code str.
+type profileRule struct {
+	CodeStr string `json:"codeStr"`
+	Prefix  string `json:"prefix"`
+	Suffix  string `json:"suffix"`
+}
+
+// A device's custom announcement settings. AnnounceTypes is keyed by announce type name, "*" matches all types
+// without their own rule.
+type profile struct {
+	VoiceID *spkVoiceID `json:"voiceId"`
+	// Overrides the voice ID if set. Gender is "male", "female" or empty for the default.
+	Language      string                 `json:"language"`
+	Gender        string                 `json:"gender"`
+	AnnounceTypes map[string]profileRule `json:"announceTypes"`
+}
+
+// Devices are keyed by device ID, IPs by the source IP of requests. Device ID profiles take precedence.
+type profilesFile struct {
+	Devices map[string]*profile `json:"devices"`
+	IPs     map[string]*profile `json:"ips"`
+}
+
+var ProfilesPath string
+var ProfilesRefreshInterval = time.Minute
+
+var profiles profilesFile
+var profilesMutex = &sync.Mutex{}
+var profilesWatch fileWatch
+
+// Returns the announce type name used as key in profiles.
+func getAnnounceTypeKeyStr(at spkAnnounceType) string {
+	switch at {
+	case SPK_ANNOUNCE_TYPE_DEFAULT:
+		return "default"
+	case SPK_ANNOUNCE_TYPE_CONNECTING:
+		return "connecting"
+	case SPK_ANNOUNCE_TYPE_CONNECTED:
+		return "connected"
+	case SPK_ANNOUNCE_TYPE_CONNECTOR_STATUS:
+		return "status"
+	case SPK_ANNOUNCE_TYPE_STARTUP:
+		return "startup"
+	case SPK_ANNOUNCE_TYPE_CONNECTED_BRANDMEISTER_SHORTENED:
+		return "connected-bm-shortened"
+	case SPK_ANNOUNCE_TYPE_DISCONNECTED:
+		return "disconnected"
+	case SPK_ANNOUNCE_TYPE_WIFI_DISCONNECTED:
+		return "wifi-disconnected"
+	case SPK_ANNOUNCE_TYPE_WIFI_CONNECTING:
+		return "wifi-connecting"
+	}
+	return "unknown"
+}
+
+func profilesLoad(path string) (profilesFile, error) {
+	var pf profilesFile
+
+	f, err := os.Open(path)
+	if err != nil {
+		return pf, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&pf); err != nil {
+		return pf, err
+	}
+
+	for _, list := range []map[string]*profile{pf.Devices, pf.IPs} {
+		for key, p := range list {
+			if err := profileCheck(p); err != nil {
+				return pf, fmt.Errorf("profile %s: %v", key, err)
+			}
+		}
+	}
+	return pf, nil
+}
+
+// Returns an error if a rule of the profile has a code str which is not made of code pairs.
+func profileCheck(p *profile) error {
+	for at, rule := range p.AnnounceTypes {
+		for name, codeStr := range map[string]string{"codeStr": rule.CodeStr, "prefix": rule.Prefix, "suffix": rule.Suffix} {
+			if len(codeStr)%2 != 0 {
+				return fmt.Errorf("%s %s \"%s\" has odd length", at, name, codeStr)
+			}
+		}
+	}
+	return nil
+}
+
+// Reloads the profiles if the file has been modified since the last load.
+func ProfilesUpdate() {
+	profilesWatch.update("profiles", ProfilesPath, func() error {
+		pf, err := profilesLoad(ProfilesPath)
+		if err != nil {
+			return err
+		}
+
+		profilesMutex.Lock()
+		profiles = pf
+		profilesMutex.Unlock()
+		log.Printf("loaded %d device and %d ip profiles\n", len(pf.Devices), len(pf.IPs))
+		return nil
+	})
+}
+
+// Returns the profile for the request's device ID, or if there's none, for the source IP.
+func profilesGet(fromAddr *net.UDPAddr, req *spkRequest) (*profile, bool) {
+	profilesMutex.Lock()
+	defer profilesMutex.Unlock()
+
+	if req.AnnounceTypeData[1] != 0 {
+		if p, ok := profiles.Devices[fmt.Sprint(req.AnnounceTypeData[1])]; ok {
+			return p, true
+		}
+	}
+	p, ok := profiles.IPs[fromAddr.IP.String()]
+	return p, ok
+}
+
+// ProfilesApply changes the request according to the requesting device's profile. Returns true if the request
+// has been changed. Pushed requests are not changed.
+func ProfilesApply(fromAddr *net.UDPAddr, req *spkRequest) bool {
+	if req.Pushed {
+		return false
+	}
+	p, ok := profilesGet(fromAddr, req)
+	if !ok {
+		return false
+	}
+
+	if p.VoiceID != nil {
+		req.VoiceID = *p.VoiceID
+	}
+	if p.Language != "" {
+		req.Language = p.Language
+		req.Gender = p.Gender
+	}
+
+	rule, ok := p.AnnounceTypes[getAnnounceTypeKeyStr(req.AnnounceType)]
+	if !ok {
+		rule = p.AnnounceTypes["*"]
+	}
+	if rule.CodeStr != "" {
+		req.CodeStr = rule.CodeStr
+	}
+	req.CodeStr = rule.Prefix + req.CodeStr + rule.Suffix
+	return true
+}
+
+func ProfilesProcess() {
+	for {
+		ProfilesUpdate()
+		time.Sleep(ProfilesRefreshInterval)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfilesLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"valid", `{"devices": {"2161": {"announceTypes": {"connected": {"codeStr": "CTTISV", "prefix": "HI"}}}}}`, false},
+		{"odd code str", `{"devices": {"2161": {"announceTypes": {"connected": {"codeStr": "CTT"}}}}}`, true},
+		{"odd prefix", `{"ips": {"10.0.0.1": {"announceTypes": {"*": {"prefix": "H"}}}}}`, true},
+		{"odd suffix", `{"ips": {"10.0.0.1": {"announceTypes": {"*": {"suffix": "TISVX"}}}}}`, true},
+		{"invalid json", `{"devices": `, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "profiles.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := profilesLoad(path); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProfilesApply(t *testing.T) {
+	saved := profiles
+	defer func() { profiles = saved }()
+
+	voiceID := spkVoiceID(SPK_VOICE_ID_FEMALE_EN)
+	profiles = profilesFile{
+		Devices: map[string]*profile{
+			"2161": {VoiceID: &voiceID, AnnounceTypes: map[string]profileRule{
+				"connected": {CodeStr: "CTTISV"},
+				"*":         {Prefix: "HI", Suffix: "BY"},
+			}},
+		},
+		IPs: map[string]*profile{
+			"10.0.0.1": {Language: "de", Gender: "female"},
+		},
+	}
+	device := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 65100}
+	other := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 65100}
+
+	tests := []struct {
+		name      string
+		addr      *net.UDPAddr
+		req       spkRequest
+		want      spkRequest
+		wantApply bool
+	}{
+		{"device rule", other,
+			spkRequest{AnnounceType: SPK_ANNOUNCE_TYPE_CONNECTED, AnnounceTypeData: [2]uint32{0, 2161}, CodeStr: "CT"},
+			spkRequest{AnnounceType: SPK_ANNOUNCE_TYPE_CONNECTED, AnnounceTypeData: [2]uint32{0, 2161}, CodeStr: "CTTISV",
+				VoiceID: SPK_VOICE_ID_FEMALE_EN}, true},
+		{"device default rule", other,
+			spkRequest{AnnounceType: SPK_ANNOUNCE_TYPE_STARTUP, AnnounceTypeData: [2]uint32{0, 2161}, CodeStr: "ST"},
+			spkRequest{AnnounceType: SPK_ANNOUNCE_TYPE_STARTUP, AnnounceTypeData: [2]uint32{0, 2161}, CodeStr: "HISTBY",
+				VoiceID: SPK_VOICE_ID_FEMALE_EN}, true},
+		{"ip", device,
+			spkRequest{AnnounceType: SPK_ANNOUNCE_TYPE_STARTUP, CodeStr: "ST"},
+			spkRequest{AnnounceType: SPK_ANNOUNCE_TYPE_STARTUP, CodeStr: "ST", Language: "de", Gender: "female"}, true},
+		{"no profile", other,
+			spkRequest{AnnounceType: SPK_ANNOUNCE_TYPE_STARTUP, CodeStr: "ST"},
+			spkRequest{AnnounceType: SPK_ANNOUNCE_TYPE_STARTUP, CodeStr: "ST"}, false},
+		{"pushed", device,
+			spkRequest{AnnounceType: SPK_ANNOUNCE_TYPE_DEFAULT, CodeStr: "TISV", Pushed: true},
+			spkRequest{AnnounceType: SPK_ANNOUNCE_TYPE_DEFAULT, CodeStr: "TISV", Pushed: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			if applied := ProfilesApply(tt.addr, &req); applied != tt.wantApply || req != tt.want {
+				t.Errorf("got %+v, %v, want %+v, %v", req, applied, tt.want, tt.wantApply)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type reflectorHost struct {
+	Number string // Empty for DCS and REF reflectors, they are identified by name.
+	Name   string
+	Host   string
+}
+
+type reflectorHostsFormat int
+
+const (
+	reflectorHostsFormatYSF      reflectorHostsFormat = iota // id;name;description;host;port;...
+	reflectorHostsFormatNumbered                             // number host port (NXDNHosts, P25Hosts)
+	reflectorHostsFormatNamed                                // name host ... (DCS and DPlus hosts)
+)
+
+type reflectorHostsFile struct {
+	ConnectorID spkConnectorId
+	Format      reflectorHostsFormat
+	Path        string
+}
+
+var ReflectorHostsFiles = []*reflectorHostsFile{
+	{ConnectorID: SPK_CONNECTOR_ID_YSFREF, Format: reflectorHostsFormatYSF},
+	{ConnectorID: SPK_CONNECTOR_ID_NXDNREF, Format: reflectorHostsFormatNumbered},
+	{ConnectorID: SPK_CONNECTOR_ID_P25REF, Format: reflectorHostsFormatNumbered},
+	{ConnectorID: SPK_CONNECTOR_ID_DCS, Format: reflectorHostsFormatNamed},
+	{ConnectorID: SPK_CONNECTOR_ID_REF, Format: reflectorHostsFormatNamed},
+}
+
+var ReflectorHostsRefreshInterval = time.Hour
+
+type reflectorHostsKey struct {
+	connectorID spkConnectorId
+	ip          string
+}
+
+var reflectorHostsIPs = make(map[reflectorHostsKey]reflectorHost)
+var reflectorHostsIPsMutex = &sync.Mutex{}
+
+// Sets the path of the hosts file for the given connector.
+func ReflectorHostsSetPath(connectorID spkConnectorId, path string) {
+	for _, f := range ReflectorHostsFiles {
+		if f.ConnectorID == connectorID {
+			f.Path = path
+		}
+	}
+}
+
+func reflectorHostsParseLine(format reflectorHostsFormat, line string) (reflectorHost, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return reflectorHost{}, false
+	}
+
+	switch format {
+	case reflectorHostsFormatYSF:
+		fields := strings.Split(line, ";")
+		if len(fields) < 4 {
+			return reflectorHost{}, false
+		}
+		return reflectorHost{Number: strings.TrimSpace(fields[0]), Name: strings.TrimSpace(fields[1]), Host: strings.TrimSpace(fields[3])}, true
+	case reflectorHostsFormatNumbered:
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return reflectorHost{}, false
+		}
+		return reflectorHost{Number: fields[0], Host: fields[1]}, true
+	case reflectorHostsFormatNamed:
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return reflectorHost{}, false
+		}
+		return reflectorHost{Name: fields[0], Host: fields[1]}, true
+	}
+	return reflectorHost{}, false
+}
+
+func reflectorHostsLoad(f *reflectorHostsFile) ([]reflectorHost, error) {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var hosts []reflectorHost
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rh, ok := reflectorHostsParseLine(f.Format, scanner.Text()); ok {
+			hosts = append(hosts, rh)
+		}
+	}
+	return hosts, scanner.Err()
+}
+
+// Loads all configured hosts files and resolves the reflector hosts to IP addresses.
+func ReflectorHostsUpdate() {
+	log.Println("updating reflector hosts")
+
+	newList := make(map[reflectorHostsKey]reflectorHost)
+	newListMutex := &sync.Mutex{}
+	var wg sync.WaitGroup
+	// Limiting concurrent DNS lookups, host files can have thousands of entries.
+	lookupSlots := make(chan struct{}, 16)
+
+	for _, f := range ReflectorHostsFiles {
+		if f.Path == "" {
+			continue
+		}
+
+		hosts, err := reflectorHostsLoad(f)
+		if err != nil {
+			log.Printf("reflector hosts load error for %s: %v\n", f.Path, err)
+			continue
+		}
+
+		for _, rh := range hosts {
+			wg.Add(1)
+			lookupSlots <- struct{}{}
+			go func(connectorID spkConnectorId, rh reflectorHost) {
+				defer wg.Done()
+				defer func() { <-lookupSlots }()
+
+				addrs, _, err := DNSLookupHost(rh.Host)
+				if err != nil {
+					return
+				}
+				newListMutex.Lock()
+				for _, addr := range addrs {
+					newList[reflectorHostsKey{connectorID, addr}] = rh
+				}
+				newListMutex.Unlock()
+			}(f.ConnectorID, rh)
+		}
+	}
+	wg.Wait()
+
+	reflectorHostsIPsMutex.Lock()
+	reflectorHostsIPs = newList
+	reflectorHostsIPsMutex.Unlock()
+	log.Printf("updating reflector hosts finished, %d addresses\n", len(newList))
+}
+
+func ReflectorHostsGet(connectorID spkConnectorId, ip string) (reflectorHost, bool) {
+	reflectorHostsIPsMutex.Lock()
+	defer reflectorHostsIPsMutex.Unlock()
+	rh, ok := reflectorHostsIPs[reflectorHostsKey{connectorID, ip}]
+	return rh, ok
+}
+
+// Returns the reflector the device is connecting to, or false if it's not a connect announcement or the server IP
+// is not in the hosts files.
+func ReflectorHostsGetForRequest(req *spkRequest) (reflectorHost, bool) {
+	if req.AnnounceType != SPK_ANNOUNCE_TYPE_CONNECTED && req.AnnounceType != SPK_ANNOUNCE_TYPE_CONNECTOR_STATUS {
+		return reflectorHost{}, false
+	}
+	return ReflectorHostsGet(req.ConnectorID, networkGetServerIPForRequest(req))
+}
+
+// Returns "reflector <number>" for numbered reflectors, "reflector <spelled name>" for the others.
+func ReflectorHostsGenerateCodeStr(rh *reflectorHost) string {
+	if rh.Number != "" {
+		return "RF" + codeStrForDigits(strings.TrimLeft(rh.Number, "0"))
+	}
+	return "RF" + codeStrForText(rh.Name, false)
+}
+
+// Resolves the reflector placeholder to the reflector the device is connecting to.
+func ReflectorHostsResolvePlaceholder(req *spkRequest, hasCodePair func(codePair string) bool) (string, bool) {
+	if rh, ok := ReflectorHostsGetForRequest(req); ok {
+		return ReflectorHostsGenerateCodeStr(&rh), true
+	}
+	return "", false
+}
+
+// ReflectorHostsApply replaces the reflector the firmware sent after "connected to" with the one from the hosts
+// files, unless the code str has the reflector placeholder. Returns true if the request has been changed.
+func ReflectorHostsApply(req *spkRequest) bool {
+	if placeholderIndex(req.CodeStr, "RFSV") >= 0 {
+		return false
+	}
+	rh, ok := ReflectorHostsGetForRequest(req)
+	if !ok {
+		return false
+	}
+	codeStr, ok := codeStrReplaceConnectedTarget(req.CodeStr, ReflectorHostsGenerateCodeStr(&rh))
+	req.CodeStr = codeStr
+	return ok
+}
+
+func ReflectorHostsProcess() {
+	for {
+		ReflectorHostsUpdate()
+		time.Sleep(ReflectorHostsRefreshInterval)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import "testing"
+
+func TestReflectorHostsParseLine(t *testing.T) {
+	tests := []struct {
+		format reflectorHostsFormat
+		line   string
+		want   reflectorHost
+		ok     bool
+	}{
+		{reflectorHostsFormatYSF, "31665;HU-Hungary;Hungarian YSF;ysf.example.org;42000;005;", reflectorHost{"31665", "HU-Hungary", "ysf.example.org"}, true},
+		{reflectorHostsFormatYSF, "31665;HU-Hungary", reflectorHost{}, false},
+		{reflectorHostsFormatNumbered, "216\tnxdn.example.org\t41400", reflectorHost{"216", "", "nxdn.example.org"}, true},
+		{reflectorHostsFormatNamed, "DCS001 dcs001.example.org", reflectorHost{"", "DCS001", "dcs001.example.org"}, true},
+		{reflectorHostsFormatNamed, "# comment", reflectorHost{}, false},
+		{reflectorHostsFormatNumbered, "", reflectorHost{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := reflectorHostsParseLine(tt.format, tt.line)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("reflectorHostsParseLine(%q) = %+v, %v, want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestReflectorHostsGenerateCodeStr(t *testing.T) {
+	tests := []struct {
+		rh   reflectorHost
+		want string
+	}{
+		{reflectorHost{Number: "31665"}, "RF0301060605"},
+		{reflectorHost{Number: "00216"}, "RF020106"},
+		{reflectorHost{Name: "DCS001"}, "RFADACAS000001"},
+	}
+	for _, tt := range tests {
+		if got := ReflectorHostsGenerateCodeStr(&tt.rh); got != tt.want {
+			t.Errorf("ReflectorHostsGenerateCodeStr(%+v) = %q, want %q", tt.rh, got, tt.want)
+		}
+	}
+}
+
+func TestReflectorHostsApply(t *testing.T) {
+	reflectorHostsIPsMutex.Lock()
+	reflectorHostsIPs = map[reflectorHostsKey]reflectorHost{
+		{SPK_CONNECTOR_ID_YSFREF, "10.0.0.1"}: {Number: "31665"},
+	}
+	reflectorHostsIPsMutex.Unlock()
+
+	tests := []struct {
+		name    string
+		ip      uint32
+		at      spkAnnounceType
+		codeStr string
+		want    string
+		applied bool
+	}{
+		{"replaced", 0x0a000001, SPK_ANNOUNCE_TYPE_CONNECTED, "CTRF0102", "CTRF0301060605", true},
+		{"unknown server", 0x0a000002, SPK_ANNOUNCE_TYPE_CONNECTED, "CTRF0102", "CTRF0102", false},
+		{"type and rest kept", 0x0a000001, SPK_ANNOUNCE_TYPE_CONNECTED, "CECTYSRF0102VE", "CECTRF0301060605VE", true},
+		{"spelled name", 0x0a000001, SPK_ANNOUNCE_TYPE_CONNECTED, "CTAHAUDS0106", "CTRF0301060605", true},
+		{"no connected to", 0x0a000001, SPK_ANNOUNCE_TYPE_CONNECTED, "CDRF0102", "CDRF0102", false},
+		{"placeholder", 0x0a000001, SPK_ANNOUNCE_TYPE_CONNECTED, "CTRFSV", "CTRFSV", false},
+		{"placeholder not on a code pair boundary", 0x0a000001, SPK_ANNOUNCE_TYPE_CONNECTED, "CTARFSV1", "CTRF0301060605FSV1", true},
+		{"other announce type", 0x0a000001, SPK_ANNOUNCE_TYPE_STARTUP, "CTRF0102", "CTRF0102", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := spkRequest{ConnectorID: SPK_CONNECTOR_ID_YSFREF, AnnounceType: tt.at, AnnounceTypeData: [2]uint32{tt.ip, 0},
+				CodeStr: tt.codeStr}
+			if applied := ReflectorHostsApply(&req); applied != tt.applied || req.CodeStr != tt.want {
+				t.Errorf("got %q, %v, want %q, %v", req.CodeStr, applied, tt.want, tt.applied)
+			}
+		})
+	}
+}
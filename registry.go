@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A device registered for pushed announcements.
+type registration struct {
+	DeviceID  uint32
+	ModemMode spkModemMode
+	VoiceID   spkVoiceID
+	// Empty if the voice is selected by VoiceID.
+	Language string
+	Gender   string
+	Timeslot uint8
+	Groups   []string
+	Addr     string
+	Expires  time.Time
+
+	// Pushed announcements are sent with the protocol version the device registered with.
+	protocol *spkProtocol
+	udpConn  *net.UDPConn
+	addr     net.UDPAddr
+}
+
+// Registrations expire if the device doesn't re-register in this time.
+var RegistryExpiry = 5 * time.Minute
+
+// Registrations are keyed by device ID. A registration is bound to the address it came from until it expires, so
+// other hosts can't take over a device's pushed announcements by sending its device ID.
+var registrations = make(map[string]*registration)
+var registrationsMutex = &sync.Mutex{}
+
+func registryGetKey(deviceID uint32, addr *net.UDPAddr) string {
+	if deviceID == 0 {
+		return addr.String()
+	}
+	return fmt.Sprint(deviceID)
+}
+
+// RegistryAdd adds or refreshes the registration of the device at addr. Returns the time until it expires, or an
+// error if the device is registered from another address.
+func RegistryAdd(udpConn *net.UDPConn, addr *net.UDPAddr, reg *registration) (time.Duration, error) {
+	now := time.Now()
+	// Version 2 is the first with registration.
+	if reg.protocol == nil {
+		reg.protocol = v2Protocol
+	}
+	reg.Addr = addr.String()
+	reg.Expires = now.Add(RegistryExpiry)
+	reg.udpConn = udpConn
+	reg.addr = *addr
+
+	key := registryGetKey(reg.DeviceID, addr)
+
+	registrationsMutex.Lock()
+	old, known := registrations[key]
+	if known && old.Addr != reg.Addr && now.Before(old.Expires) {
+		registrationsMutex.Unlock()
+		return 0, fmt.Errorf("device %d is registered from %s until %s", reg.DeviceID, old.Addr, old.Expires.Format(time.TimeOnly))
+	}
+	registrations[key] = reg
+	registrationsMutex.Unlock()
+
+	if !known || old.Addr != reg.Addr {
+		log.Printf("registered device %d at %s (t:%s groups:%v)\n", reg.DeviceID, reg.Addr, getModemModeNameStr(reg.ModemMode), reg.Groups)
+	}
+	return RegistryExpiry, nil
+}
+
+// Returns the groups of a register packet's comma separated, zero padded group list.
+func registryParseGroups(groupsField []byte) []string {
+	var groups []string
+	for _, group := range strings.Split(strings.TrimRight(string(groupsField), "\x00"), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// Registers the device sending a register packet and acknowledges it. Registrations of devices registered from
+// another address are not acknowledged.
+func registryProcessRegister(udpConn *net.UDPConn, fromAddr *net.UDPAddr, reg *registration) {
+	expiry, err := RegistryAdd(udpConn, fromAddr, reg)
+	if err != nil {
+		log.Printf("ignoring registration from %s: %v\n", fromAddr.String(), err)
+		return
+	}
+
+	ack := spkRegisterAckPacketv2{PacketType: SPK_PACKET_TYPE_REGISTER_ACK, Version: reg.protocol.Version,
+		DeviceID: reg.DeviceID, Expiry: uint16(min(expiry/time.Second, 0xffff))}
+	copy(ack.Magic[:], SPK_PACKET_MAGIC)
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, &ack)
+
+	// The ack is not part of a stream, so it's sent right away.
+	writtenBytes, err := udpConn.WriteToUDP(buf.Bytes(), fromAddr)
+	if writtenBytes != buf.Len() || err != nil {
+		log.Printf("warning: can't send register ack to %s\n", fromAddr.String())
+	}
+}
+
+// Returns the active registrations in the given group. An empty group matches all registrations.
+func RegistryGet(group string) []registration {
+	now := time.Now()
+
+	registrationsMutex.Lock()
+	defer registrationsMutex.Unlock()
+
+	var res []registration
+	for _, reg := range registrations {
+		if now.After(reg.Expires) {
+			continue
+		}
+		if group != "" && !slices.Contains(reg.Groups, group) {
+			continue
+		}
+		res = append(res, *reg)
+	}
+	return res
+}
+
+// RegistryPush plays codeStr to all registered devices in the given group. The devices' voice is used if voiceID
+// is nil. Returns the number of devices the announcement was pushed to.
+func RegistryPush(group string, codeStr string, voiceID *spkVoiceID) int {
+	regs := RegistryGet(group)
+	log.Printf("pushing \"%s\" to %d devices in group \"%s\"\n", codeStr, len(regs), group)
+
+	for _, reg := range regs {
+		req := &spkRequest{
+			SessionID:    rand.Uint32(),
+			ConnectorID:  SPK_CONNECTOR_ID_UNKNOWN,
+			AnnounceType: SPK_ANNOUNCE_TYPE_DEFAULT,
+			ModemMode:    reg.ModemMode,
+			VoiceID:      reg.VoiceID,
+			Language:     reg.Language,
+			Gender:       reg.Gender,
+			Timeslot:     reg.Timeslot,
+			CodeStr:      codeStr,
+			Pushed:       true,
+		}
+		// The given voice ID overrides the device's language.
+		if voiceID != nil {
+			req.VoiceID = *voiceID
+			req.Language, req.Gender = "", ""
+		}
+		processRequest(reg.udpConn, &reg.addr, reg.protocol, req)
+	}
+	return len(regs)
+}
+
+func registryCleanup() {
+	now := time.Now()
+
+	registrationsMutex.Lock()
+	defer registrationsMutex.Unlock()
+
+	for key, reg := range registrations {
+		if now.After(reg.Expires) {
+			log.Printf("registration of device %d at %s expired\n", reg.DeviceID, reg.Addr)
+			delete(registrations, key)
+		}
+	}
+}
+
+func RegistryProcess() {
+	for {
+		time.Sleep(time.Minute)
+		registryCleanup()
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegistryAdd(t *testing.T) {
+	device := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 65100}
+	other := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 65100}
+
+	tests := []struct {
+		name     string
+		deviceID uint32
+		addr     *net.UDPAddr
+		expired  bool
+		wantErr  bool
+		wantAddr string
+	}{
+		{"refresh", 2161, device, false, false, device.String()},
+		{"other address", 2161, other, false, true, device.String()},
+		{"other address after expiry", 2161, other, true, false, other.String()},
+		{"other device", 2162, other, false, false, device.String()},
+		{"no device id", 0, other, false, false, device.String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registrationsMutex.Lock()
+			registrations = make(map[string]*registration)
+			registrationsMutex.Unlock()
+
+			if _, err := RegistryAdd(nil, device, &registration{DeviceID: 2161}); err != nil {
+				t.Fatal(err)
+			}
+			if tt.expired {
+				registrationsMutex.Lock()
+				registrations["2161"].Expires = time.Now().Add(-time.Second)
+				registrationsMutex.Unlock()
+			}
+
+			_, err := RegistryAdd(nil, tt.addr, &registration{DeviceID: tt.deviceID})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+			registrationsMutex.Lock()
+			addr := registrations["2161"].Addr
+			registrationsMutex.Unlock()
+			if addr != tt.wantAddr {
+				t.Errorf("device 2161 registered at %s, want %s", addr, tt.wantAddr)
+			}
+		})
+	}
+}
+
+func TestRegistryRegisterPackets(t *testing.T) {
+	sent := schedulerTestState(t, 10)
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	device, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+	deviceAddr := device.LocalAddr().(*net.UDPAddr)
+
+	v2Packet := spkRegisterPacketv2{Version: 2, PacketType: SPK_PACKET_TYPE_REGISTER, DeviceID: 2161,
+		ModemMode: SPK_MODEM_MODE_DMR, VoiceID: SPK_VOICE_ID_FEMALE_EN, Timeslot: 2}
+	copy(v2Packet.Groups[:], "club, net")
+	v3Packet := spkRegisterPacketv3{Version: 3, PacketType: SPK_PACKET_TYPE_REGISTER, DeviceID: 2161,
+		ModemMode: SPK_MODEM_MODE_DMR, Language: [2]byte{'H', 'U'}, Gender: SPK_VOICE_GENDER_FEMALE, Timeslot: 2}
+	copy(v3Packet.Groups[:], "club")
+
+	tests := []struct {
+		name         string
+		packet       interface{}
+		process      func(udpConn *net.UDPConn, fromAddr *net.UDPAddr, buffer []byte, readBytes int)
+		wantProtocol *spkProtocol
+		wantLanguage string
+		wantGender   string
+		wantGroups   []string
+	}{
+		{"v2", &v2Packet, v2processPacket, v2Protocol, "", "", []string{"club", "net"}},
+		{"v3", &v3Packet, v3processPacket, v3Protocol, "hu", "female", []string{"club"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registrationsMutex.Lock()
+			registrations = make(map[string]*registration)
+			registrationsMutex.Unlock()
+
+			var buf bytes.Buffer
+			binary.Write(&buf, binary.BigEndian, tt.packet)
+			copy(buf.Bytes(), SPK_PACKET_MAGIC)
+			tt.process(server, deviceAddr, buf.Bytes(), buf.Len())
+
+			ackBuf := make([]byte, 64)
+			device.SetReadDeadline(time.Now().Add(time.Second))
+			n, err := device.Read(ackBuf)
+			if err != nil {
+				t.Fatalf("no register ack: %v", err)
+			}
+			var ack spkRegisterAckPacketv2
+			if n != SPK_REGISTER_ACK_PACKET_V2_SIZE || binary.Read(bytes.NewReader(ackBuf[:n]), binary.BigEndian, &ack) != nil {
+				t.Fatalf("invalid register ack %x", ackBuf[:n])
+			}
+			if ack.Version != tt.wantProtocol.Version || ack.DeviceID != 2161 || ack.PacketType != SPK_PACKET_TYPE_REGISTER_ACK {
+				t.Errorf("got ack %+v", ack)
+			}
+			if len(*sent) != 0 {
+				t.Errorf("the ack was sent through the scheduler")
+			}
+
+			regs := RegistryGet("")
+			if len(regs) != 1 {
+				t.Fatalf("got %d registrations, want 1", len(regs))
+			}
+			reg := regs[0]
+			if reg.protocol != tt.wantProtocol || reg.Language != tt.wantLanguage || reg.Gender != tt.wantGender ||
+				!reflect.DeepEqual(reg.Groups, tt.wantGroups) || reg.Timeslot != 2 {
+				t.Errorf("got registration %+v", reg)
+			}
+		})
+	}
+}
+
+func TestRegistryPushVoiceAndProtocol(t *testing.T) {
+	voiceTestPacks(t, "srf-female-hu", "srf-male-en", "srf-female-en")
+	streamTestAssets(t, voicePacksDir+"srf-female-hu/dmr", spkCodecDMR.FrameSize, map[string][]byte{"A1": {0x51}})
+	streamTestAssets(t, voicePacksDir+"srf-male-en/dmr", spkCodecDMR.FrameSize, map[string][]byte{"A1": {0x11}})
+	streamTestAssets(t, voicePacksDir+"srf-female-en/dmr", spkCodecDMR.FrameSize, map[string][]byte{"A1": {0x21}})
+
+	maleEN := spkVoiceID(SPK_VOICE_ID_MALE_EN)
+	tests := []struct {
+		name        string
+		reg         registration
+		voiceID     *spkVoiceID
+		wantVersion uint8
+		wantFrame   byte
+	}{
+		{"v2 voice id", registration{VoiceID: SPK_VOICE_ID_FEMALE_EN, protocol: v2Protocol}, nil, 2, 0x21},
+		{"v3 language", registration{Language: "hu", Gender: "female", protocol: v3Protocol}, nil, 3, 0x51},
+		{"v3 with pushed voice id", registration{Language: "hu", Gender: "female", protocol: v3Protocol}, &maleEN, 3, 0x11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedulerTestState(t, 10)
+			var packets []streamTestPacket
+			var versions []uint8
+			schedulerSendPacket = func(udpConn *net.UDPConn, toAddr *net.UDPAddr, data []byte) {
+				packets = append(packets, streamTestDecode(t, spkCodecDMR, data))
+				versions = append(versions, data[6])
+			}
+			registrationsMutex.Lock()
+			registrations = make(map[string]*registration)
+			registrationsMutex.Unlock()
+
+			reg := tt.reg
+			reg.DeviceID, reg.ModemMode = 2161, SPK_MODEM_MODE_DMR
+			if _, err := RegistryAdd(nil, &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 65100}, &reg); err != nil {
+				t.Fatal(err)
+			}
+			if n := RegistryPush("", "A1", tt.voiceID); n != 1 {
+				t.Fatalf("pushed to %d devices, want 1", n)
+			}
+			schedulerProcessTick(11)
+
+			if len(packets) != 1 || !reflect.DeepEqual(packets[0].frames, []byte{tt.wantFrame}) {
+				t.Fatalf("got packets %v, want one with frame %x", packets, tt.wantFrame)
+			}
+			if versions[0] != tt.wantVersion {
+				t.Errorf("got protocol version %d, want %d", versions[0], tt.wantVersion)
+			}
+		})
+	}
+}
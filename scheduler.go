@@ -0,0 +1,140 @@
+package main
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Each response packet carries 3 frames of 20ms.
+const schedulerPacketInterval = 3 * 20 * time.Millisecond
+
+// Number of response packets sent back-to-back at stream start.
+var SchedulerPrebufferPackets = 0
+
+// A stream produces the response packets of an announcement, one packet at a time. It's never called from more
+// than one goroutine at a time, so it doesn't need locking.
+type schedulerStream interface {
+	// nextPacket returns the next encoded packet and true if it's the last packet of the stream. If the stream is
+	// not ready to send yet, it returns nil and false, and it's called again on the next tick.
+	nextPacket() ([]byte, bool)
+	// finished is called after the last packet has been sent.
+	finished()
+}
+
+type schedulerSession struct {
+	udpConn     *net.UDPConn
+	toAddr      net.UDPAddr
+	stream      schedulerStream
+	startTick   int64
+	sentPackets int64
+	done        bool
+}
+
+// All packets are sent on an absolute timeline starting at schedulerEpoch, so delays in the scheduler loop don't
+// accumulate.
+var schedulerEpoch = time.Now()
+
+// The clock and the packet sender of the scheduler, replaced in tests.
+var schedulerNow = time.Now
+var schedulerSendPacket = schedulerSend
+var schedulerSessions []*schedulerSession
+var schedulerSessionsMutex = &sync.Mutex{}
+
+// Returns the index of the tick nearest to the given time.
+func schedulerGetTick(t time.Time) int64 {
+	return int64((t.Sub(schedulerEpoch) + schedulerPacketInterval/2) / schedulerPacketInterval)
+}
+
+// SchedulerAdd starts sending the given stream to toAddr. The first prebufferPackets packets are sent immediately
+// back-to-back to fill up the receiver's jitter buffer, the rest is sent in real time starting with the next tick.
+func SchedulerAdd(udpConn *net.UDPConn, toAddr net.UDPAddr, stream schedulerStream, prebufferPackets int) {
+	if prebufferPackets < 0 {
+		prebufferPackets = 0
+	}
+
+	tick := schedulerGetTick(schedulerNow())
+	ss := &schedulerSession{
+		udpConn:   udpConn,
+		toAddr:    toAddr,
+		stream:    stream,
+		startTick: tick + 1 - int64(prebufferPackets),
+	}
+
+	// The session is not yet visible to the scheduler goroutine, so we can send the burst from here.
+	ss.sendDuePackets(tick)
+	if ss.done {
+		return
+	}
+
+	schedulerSessionsMutex.Lock()
+	schedulerSessions = append(schedulerSessions, ss)
+	schedulerSessionsMutex.Unlock()
+}
+
+func schedulerSend(udpConn *net.UDPConn, toAddr *net.UDPAddr, data []byte) {
+	writtenBytes, err := udpConn.WriteToUDP(data, toAddr)
+	if writtenBytes != len(data) || err != nil {
+		log.Printf("warning: can't send udp packet to %s\n", toAddr.String())
+	}
+}
+
+// Sends all packets of the session which are due at the given tick. If the scheduler has fallen behind, more than
+// one packet is sent to catch up.
+func (ss *schedulerSession) sendDuePackets(tick int64) {
+	for !ss.done && ss.startTick+ss.sentPackets <= tick {
+		data, last := ss.stream.nextPacket()
+		if data == nil && !last {
+			// The stream is holding, shifting its timeline so it continues in real time instead of catching up.
+			ss.startTick = tick + 1 - ss.sentPackets
+			return
+		}
+		if data != nil {
+			schedulerSendPacket(ss.udpConn, &ss.toAddr, data)
+		}
+		ss.sentPackets++
+
+		if last {
+			ss.done = true
+			ss.stream.finished()
+		}
+	}
+}
+
+func schedulerProcessTick(tick int64) {
+	schedulerSessionsMutex.Lock()
+	sessions := make([]*schedulerSession, len(schedulerSessions))
+	copy(sessions, schedulerSessions)
+	schedulerSessionsMutex.Unlock()
+
+	for _, ss := range sessions {
+		ss.sendDuePackets(tick)
+	}
+
+	schedulerSessionsMutex.Lock()
+	active := schedulerSessions[:0]
+	for _, ss := range schedulerSessions {
+		if !ss.done {
+			active = append(active, ss)
+		}
+	}
+	for i := len(active); i < len(schedulerSessions); i++ {
+		schedulerSessions[i] = nil
+	}
+	schedulerSessions = active
+	schedulerSessionsMutex.Unlock()
+}
+
+// SchedulerProcess is the central send loop for all active streams.
+func SchedulerProcess() {
+	// Aligning the ticker to the timeline.
+	time.Sleep(time.Until(schedulerEpoch.Add(time.Duration(schedulerGetTick(time.Now())+1) * schedulerPacketInterval)))
+
+	ticker := time.NewTicker(schedulerPacketInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		schedulerProcessTick(schedulerGetTick(now))
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// A scripted stream. Each entry is a packet, or a hold if it's 0. The last entry is the last packet.
+type schedulerTestStream struct {
+	script   []byte
+	pos      int
+	finishes int
+}
+
+func (s *schedulerTestStream) nextPacket() ([]byte, bool) {
+	id := s.script[s.pos]
+	s.pos++
+	if id == 0 {
+		return nil, false
+	}
+	return []byte{id}, s.pos == len(s.script)
+}
+
+func (s *schedulerTestStream) finished() {
+	s.finishes++
+}
+
+// Replaces the scheduler's clock with the given tick and records the sent packets' first bytes.
+func schedulerTestState(t *testing.T, tick int64) *[]byte {
+	savedNow, savedSend, savedSessions := schedulerNow, schedulerSendPacket, schedulerSessions
+	t.Cleanup(func() {
+		schedulerNow, schedulerSendPacket, schedulerSessions = savedNow, savedSend, savedSessions
+	})
+
+	sent := &[]byte{}
+	schedulerNow = func() time.Time {
+		return schedulerEpoch.Add(time.Duration(tick) * schedulerPacketInterval)
+	}
+	schedulerSendPacket = func(udpConn *net.UDPConn, toAddr *net.UDPAddr, data []byte) {
+		*sent = append(*sent, data[0])
+	}
+	schedulerSessions = nil
+	return sent
+}
+
+func TestSchedulerTimeline(t *testing.T) {
+	type tick struct {
+		tick int64
+		want []byte
+	}
+	tests := []struct {
+		name      string
+		script    []byte
+		prebuffer int
+		// Packets sent by SchedulerAdd at tick 10.
+		wantAdd []byte
+		ticks   []tick
+	}{
+		{
+			name:    "real time from the next tick",
+			script:  []byte{1, 2, 3},
+			wantAdd: []byte{},
+			ticks:   []tick{{10, []byte{}}, {11, []byte{1}}, {12, []byte{2}}, {13, []byte{3}}, {14, []byte{}}},
+		},
+		{
+			name:      "prebuffer burst",
+			script:    []byte{1, 2, 3, 4, 5},
+			prebuffer: 3,
+			wantAdd:   []byte{1, 2, 3},
+			ticks:     []tick{{10, []byte{}}, {11, []byte{4}}, {12, []byte{5}}},
+		},
+		{
+			name:      "prebuffer longer than the stream",
+			script:    []byte{1, 2},
+			prebuffer: 3,
+			wantAdd:   []byte{1, 2},
+			ticks:     []tick{{11, []byte{}}},
+		},
+		{
+			name:    "late tick catches up",
+			script:  []byte{1, 2, 3, 4, 5},
+			wantAdd: []byte{},
+			ticks:   []tick{{11, []byte{1}}, {14, []byte{2, 3, 4}}, {15, []byte{5}}},
+		},
+		{
+			name:    "hold shifts the timeline",
+			script:  []byte{1, 0, 0, 2, 3},
+			wantAdd: []byte{},
+			ticks:   []tick{{11, []byte{1}}, {12, []byte{}}, {13, []byte{}}, {14, []byte{2}}, {15, []byte{3}}},
+		},
+		{
+			name:    "late tick after a hold",
+			script:  []byte{1, 0, 2, 3, 4, 5},
+			wantAdd: []byte{},
+			ticks:   []tick{{11, []byte{1}}, {12, []byte{}}, {15, []byte{2, 3, 4}}, {16, []byte{5}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := schedulerTestState(t, 10)
+			stream := &schedulerTestStream{script: tt.script}
+
+			SchedulerAdd(nil, net.UDPAddr{}, stream, tt.prebuffer)
+			if got := *sent; !reflect.DeepEqual(got, tt.wantAdd) {
+				t.Errorf("SchedulerAdd() sent %v, want %v", got, tt.wantAdd)
+			}
+
+			for _, tk := range tt.ticks {
+				*sent = []byte{}
+				schedulerProcessTick(tk.tick)
+				if got := *sent; !reflect.DeepEqual(got, tk.want) {
+					t.Errorf("tick %d sent %v, want %v", tk.tick, got, tk.want)
+				}
+			}
+
+			if stream.pos != len(stream.script) {
+				t.Errorf("stream read up to %d, want %d", stream.pos, len(stream.script))
+			}
+			if stream.finishes != 1 {
+				t.Errorf("finished() called %d times, want 1", stream.finishes)
+			}
+			if len(schedulerSessions) != 0 {
+				t.Errorf("%d sessions left, want 0", len(schedulerSessions))
+			}
+		})
+	}
+}
+
+func TestSchedulerSessionsInterleave(t *testing.T) {
+	sent := schedulerTestState(t, 10)
+
+	first := &schedulerTestStream{script: []byte{1, 2}}
+	second := &schedulerTestStream{script: []byte{11, 12, 13}}
+	SchedulerAdd(nil, net.UDPAddr{}, first, 0)
+	SchedulerAdd(nil, net.UDPAddr{}, second, 0)
+
+	for tick := int64(11); tick <= 13; tick++ {
+		schedulerProcessTick(tick)
+	}
+	if want := []byte{1, 11, 2, 12, 13}; !reflect.DeepEqual(*sent, want) {
+		t.Errorf("sent %v, want %v", *sent, want)
+	}
+	if len(schedulerSessions) != 0 {
+		t.Errorf("%d sessions left, want 0", len(schedulerSessions))
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A scheduled announcement pushed to registered devices. Either CodeStr or Macro is set.
+type schedule struct {
+	Cron    string      `json:"cron"`
+	CodeStr string      `json:"codeStr"`
+	Macro   string      `json:"macro"`
+	Group   string      `json:"group"`
+	VoiceID *spkVoiceID `json:"voiceId"`
+
+	cron *cronExpr
+}
+
+type schedulesFile struct {
+	// Named code strings which can be used by schedules.
+	Macros    map[string]string `json:"macros"`
+	Schedules []*schedule       `json:"schedules"`
+}
+
+// Minute, hour, day of month, month and day of week fields, each is a set of the matching values.
+type cronExpr struct {
+	minute, hour, dom, month, dow map[int]bool
+	domAny, dowAny                bool
+}
+
+var SchedulesPath string
+
+var schedules schedulesFile
+var schedulesMutex = &sync.Mutex{}
+var schedulesWatch fileWatch
+
+// Parses a cron field like "*", "5", "1-5", "*/15", "0-30/10" or a comma separated list of these.
+func cronParseField(field string, minValue int, maxValue int) (map[int]bool, error) {
+	values := make(map[int]bool)
+
+	for _, part := range strings.Split(field, ",") {
+		rangeStr, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in \"%s\"", part)
+			}
+		}
+
+		from, to := minValue, maxValue
+		if rangeStr != "*" {
+			fromStr, toStr, isRange := strings.Cut(rangeStr, "-")
+			var err error
+			if from, err = strconv.Atoi(fromStr); err != nil {
+				return nil, fmt.Errorf("invalid value in \"%s\"", part)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(toStr); err != nil {
+					return nil, fmt.Errorf("invalid value in \"%s\"", part)
+				}
+			} else if hasStep {
+				to = maxValue
+			}
+		}
+		if from < minValue || to > maxValue || from > to {
+			return nil, fmt.Errorf("\"%s\" is out of range %d-%d", part, minValue, maxValue)
+		}
+
+		for v := from; v <= to; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// Returns true if the field matches every value from minValue to maxValue.
+func cronFieldIsAny(values map[int]bool, minValue int, maxValue int) bool {
+	for v := minValue; v <= maxValue; v++ {
+		if !values[v] {
+			return false
+		}
+	}
+	return true
+}
+
+// Parses a standard 5 field cron expression: minute hour day-of-month month day-of-week.
+func cronParse(expr string) (*cronExpr, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression \"%s\" needs 5 fields", expr)
+	}
+
+	var c cronExpr
+	var err error
+	if c.minute, err = cronParseField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if c.hour, err = cronParseField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if c.dom, err = cronParseField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if c.month, err = cronParseField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	// Both 0 and 7 are Sunday.
+	if c.dow, err = cronParseField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if c.dow[7] {
+		c.dow[0] = true
+	}
+	// Fields like "*/1" or "1-31" match every day too, so they don't make the day fields restricted.
+	c.domAny = cronFieldIsAny(c.dom, 1, 31)
+	c.dowAny = cronFieldIsAny(c.dow, 0, 6)
+	return &c, nil
+}
+
+func (c *cronExpr) matches(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+
+	// Like in cron, if both day fields are restricted, matching either of them is enough.
+	domMatch := c.dom[t.Day()]
+	dowMatch := c.dow[int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowMatch
+	case c.dowAny:
+		return domMatch
+	}
+	return domMatch || dowMatch
+}
+
+func schedulesLoad(path string) (schedulesFile, error) {
+	var sf schedulesFile
+
+	f, err := os.Open(path)
+	if err != nil {
+		return sf, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&sf); err != nil {
+		return sf, err
+	}
+
+	for name, codeStr := range sf.Macros {
+		if len(codeStr)%2 != 0 {
+			return sf, fmt.Errorf("macro \"%s\" code str \"%s\" has odd length", name, codeStr)
+		}
+	}
+	for i, sch := range sf.Schedules {
+		if len(sch.CodeStr)%2 != 0 {
+			return sf, fmt.Errorf("schedule %d: code str \"%s\" has odd length", i+1, sch.CodeStr)
+		}
+		if sch.cron, err = cronParse(sch.Cron); err != nil {
+			return sf, fmt.Errorf("schedule %d: %v", i+1, err)
+		}
+		if sch.Macro != "" {
+			if _, ok := sf.Macros[sch.Macro]; !ok {
+				return sf, fmt.Errorf("schedule %d: unknown macro \"%s\"", i+1, sch.Macro)
+			}
+		} else if sch.CodeStr == "" {
+			return sf, fmt.Errorf("schedule %d: no code str or macro", i+1)
+		}
+	}
+	return sf, nil
+}
+
+// Reloads the schedules if the file has been modified since the last load.
+func SchedulesUpdate() {
+	schedulesWatch.update("schedules", SchedulesPath, func() error {
+		sf, err := schedulesLoad(SchedulesPath)
+		if err != nil {
+			return err
+		}
+
+		schedulesMutex.Lock()
+		schedules = sf
+		schedulesMutex.Unlock()
+		log.Printf("loaded %d schedules\n", len(sf.Schedules))
+		return nil
+	})
+}
+
+// Pushes the announcements scheduled for the given minute.
+func schedulesRun(t time.Time) {
+	schedulesMutex.Lock()
+	sf := schedules
+	schedulesMutex.Unlock()
+
+	for _, sch := range sf.Schedules {
+		if !sch.cron.matches(t) {
+			continue
+		}
+
+		codeStr := sch.CodeStr
+		if sch.Macro != "" {
+			codeStr = sf.Macros[sch.Macro]
+		}
+		log.Printf("running schedule \"%s\"\n", sch.Cron)
+		RegistryPush(sch.Group, codeStr, sch.VoiceID)
+	}
+}
+
+func SchedulesProcess() {
+	for {
+		SchedulesUpdate()
+
+		// Waking up right after each minute starts.
+		now := time.Now().In(ClockLocation)
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		time.Sleep(next.Sub(now))
+		schedulesRun(next)
+	}
+}
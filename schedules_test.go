@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCronParseField(t *testing.T) {
+	tests := []struct {
+		field   string
+		want    []int
+		wantErr bool
+	}{
+		{"*", []int{0, 1, 2, 3, 4, 5, 6}, false},
+		{"5", []int{5}, false},
+		{"1-3", []int{1, 2, 3}, false},
+		{"*/3", []int{0, 3, 6}, false},
+		{"1-6/2", []int{1, 3, 5}, false},
+		{"2/2", []int{2, 4, 6}, false},
+		{"1,3-4,6", []int{1, 3, 4, 6}, false},
+		{"7", nil, true},
+		{"4-2", nil, true},
+		{"*/0", nil, true},
+		{"*/x", nil, true},
+		{"a", nil, true},
+		{"1-b", nil, true},
+		{"", nil, true},
+	}
+	for _, tt := range tests {
+		values, err := cronParseField(tt.field, 0, 6)
+		var got []int
+		for v := range values {
+			got = append(got, v)
+		}
+		sort.Ints(got)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("cronParseField(%q) = %v, %v, want %v, error %v", tt.field, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2026-10-19 is a Monday.
+	monday := time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)
+	sunday := time.Date(2026, 10, 18, 8, 30, 0, 0, time.UTC)
+	first := time.Date(2026, 11, 1, 8, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		t    time.Time
+		want bool
+	}{
+		{"* * * * *", monday, true},
+		{"30 8 * * *", monday, true},
+		{"31 8 * * *", monday, false},
+		{"*/15 8-17 * * *", monday, true},
+		{"*/15 9-17 * * *", monday, false},
+		{"30 8 * * 1-5", monday, true},
+		{"30 8 * * 1-5", sunday, false},
+		{"30 8 * * 0", sunday, true},
+		{"30 8 * * 7", sunday, true},
+		{"30 8 19 10 *", monday, true},
+		{"30 8 19 11 *", monday, false},
+		{"30 8 1 * *", monday, false},
+		// Both day fields are restricted, matching either is enough.
+		{"30 8 1 * 1", monday, true},
+		{"30 8 1 * 1", first, true},
+		{"30 8 1 * 1", sunday, false},
+		// Restrictions matching every day are not restrictions.
+		{"30 8 */1 * 1", monday, true},
+		{"30 8 */1 * 1", sunday, false},
+		{"30 8 1-31 * 0", monday, false},
+		{"30 8 1 * 0-6", monday, false},
+		{"30 8 1 * 1-7", first, true},
+		{"30 8 1 * 1-7", monday, false},
+	}
+	for _, tt := range tests {
+		c, err := cronParse(tt.expr)
+		if err != nil {
+			t.Fatalf("cronParse(%q): %v", tt.expr, err)
+		}
+		if got := c.matches(tt.t); got != tt.want {
+			t.Errorf("%q matches %s = %v, want %v", tt.expr, tt.t.Format(time.RFC1123), got, tt.want)
+		}
+	}
+}
+
+func TestCronParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8"} {
+		if _, err := cronParse(expr); err == nil {
+			t.Errorf("expected an error for %q", expr)
+		}
+	}
+}
+
+func TestSchedulesLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"code str", `{"schedules": [{"cron": "0 * * * *", "codeStr": "TISV"}]}`, false},
+		{"macro", `{"macros": {"time": "TISV"}, "schedules": [{"cron": "0 * * * *", "macro": "time"}]}`, false},
+		{"unknown macro", `{"schedules": [{"cron": "0 * * * *", "macro": "time"}]}`, true},
+		{"nothing to say", `{"schedules": [{"cron": "0 * * * *"}]}`, true},
+		{"invalid cron", `{"schedules": [{"cron": "0 * *", "codeStr": "TISV"}]}`, true},
+		{"odd length code str", `{"schedules": [{"cron": "0 * * * *", "codeStr": "TISV0"}]}`, true},
+		{"odd length macro", `{"macros": {"time": "TIS"}, "schedules": [{"cron": "0 * * * *", "codeStr": "TISV"}]}`, true},
+		{"invalid json", `{"schedules": [`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "schedules.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := schedulesLoad(path); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,8 +3,6 @@
 package main
 
 import (
-	"bytes"
-	"encoding/binary"
 	"flag"
 	"io"
 	"log"
@@ -14,52 +12,81 @@ import (
 	"time"
 )
 
-func sendAMBEAnswer(udpConn *net.UDPConn, toAddr *net.UDPAddr, res *spkAMBEResponsePacket) {
-	var buf bytes.Buffer
-	if err := binary.Write(&buf, binary.BigEndian, res); err != nil {
-		log.Printf("send answer error: %v", err)
-		return
-	}
-	writtenBytes, err := udpConn.WriteToUDP(buf.Bytes(), toAddr)
-	if writtenBytes != SPK_AMBE_RESPONSE_PACKET_SIZE || err != nil {
-		log.Printf("warning: can't send udp packet to %s\n", toAddr.String())
-	}
-
-	if res.PacketType != SPK_PACKET_TYPE_RESPONSE_TERMINATOR {
-		res.SeqNum++
-		time.Sleep(time.Duration(res.FrameCount) * 20 * time.Millisecond)
-	}
-}
-
-func sendIMBEAnswer(udpConn *net.UDPConn, toAddr *net.UDPAddr, res *spkIMBEResponsePacket) {
-	var buf bytes.Buffer
-	if err := binary.Write(&buf, binary.BigEndian, res); err != nil {
-		log.Printf("send answer error: %v", err)
-		return
-	}
-	writtenBytes, err := udpConn.WriteToUDP(buf.Bytes(), toAddr)
-	if writtenBytes != SPK_IMBE_RESPONSE_PACKET_SIZE || err != nil {
-		log.Printf("warning: can't send udp packet to %s\n", toAddr.String())
-	}
-
-	if res.PacketType != SPK_PACKET_TYPE_RESPONSE_TERMINATOR {
-		res.SeqNum++
-		time.Sleep(time.Duration(res.FrameCount) * 20 * time.Millisecond)
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "voices" {
+		os.Exit(VoicesCommand(os.Args[2:]))
 	}
-}
 
-func main() {
 	var bindIp = ""
 	var bindPort = 65200
 	var silent bool
 	var logToFile bool
+	var healthAddr string
+	var serverListGracePeriod = 30 * time.Second
+	var ysfMode = "dn"
+	var fakeBMAddr string
+	var fakeBMDataPath string
+	var ysfHostsPath, nxdnHostsPath, p25HostsPath, dcsHostsPath, refHostsPath string
+	var echolinkNodesPath, allstarNodesPath string
+	var timeZone string
+	var dnsServer string
+	var adminAddr string
+	var languageFallbacks string
 
 	flag.IntVar(&bindPort, "p", bindPort, "bind to port")
 	flag.StringVar(&bindIp, "i", bindIp, "bind to ip address")
 	flag.BoolVar(&silent, "s", false, "disable logging")
 	flag.BoolVar(&logToFile, "f", false, "log to file spk-srv.log")
+	flag.IntVar(&SchedulerPrebufferPackets, "prebuffer", SchedulerPrebufferPackets, "send this many response packets back-to-back at stream start")
+	flag.BoolVar(&CodecNativeEnabled, "nativecodecs", CodecNativeEnabled, "send native ysf and nxdn frames if the voice has assets for them")
+	flag.StringVar(&ysfMode, "ysfmode", ysfMode, "ysf voice mode for native c4fm frames (dn or vw)")
+	flag.DurationVar(&NetworkCacheTTL, "cachettl", NetworkCacheTTL, "cache network client status for this long")
+	flag.DurationVar(&NetworkCacheNegativeTTL, "cachenegttl", NetworkCacheNegativeTTL, "cache failed network client status lookups for this long")
+	flag.DurationVar(&NetworkCacheMaxStale, "cachestale", NetworkCacheMaxStale, "serve cached network client status up to this old if the network api fails (0 disables)")
+	flag.StringVar(&BMAPIURL, "bmapi", BMAPIURL, "bm api base url")
+	flag.StringVar(&NetworkServerListURL, "serverlist", NetworkServerListURL, "homebrew server list url (empty disables)")
+	flag.StringVar(&dnsServer, "dns", "", "resolve host names with this dns server (e.g. 1.1.1.1:53) or dns over https url, the system resolver is used if empty")
+	flag.StringVar(&NetworkServerListPath, "serverlistfile", "", "add the servers from this static server list json file, replacing remote servers with the same host")
+	flag.StringVar(&NetworkServerListCachePath, "serverlistcache", "", "save the resolved server list to this file and load it at startup")
+	flag.DurationVar(&NetworkServerListRefreshInterval, "serverlistrefresh", NetworkServerListRefreshInterval, "update the server list this often")
+	flag.StringVar(&TGIFAPIURL, "tgifapi", "", "tgif hotspot status api url, {id} is replaced by the client id (empty disables)")
+	flag.StringVar(&FreeDMRAPIURL, "freedmrapi", "", "freedmr/hblink peer status api url, {id} is replaced by the client id, {host} by the server host (empty disables)")
+	flag.StringVar(&DMRPlusAPIURL, "dmrplusapi", "", "dmr+ master status api url, {id} is replaced by the client id, {host} by the master ip (empty disables)")
+	flag.StringVar(&fakeBMAddr, "fakebm", "", "start a fake bm api server on this address (e.g. 127.0.0.1:8081) and use it")
+	flag.StringVar(&fakeBMDataPath, "fakebmdata", "", "load the fake bm api server's canned data from this json file")
+	flag.BoolVar(&NetworkTimeslotFilter, "slotfilter", false, "announce only the talkgroups on the timeslot reported by the device")
+	flag.StringVar(&TGDBPath, "tgdb", "", "load talkgroup names from this csv or json file")
+	flag.DurationVar(&TGDBRefreshInterval, "tgdbrefresh", TGDBRefreshInterval, "check the talkgroup name file for changes this often")
+	flag.StringVar(&ysfHostsPath, "ysfhosts", "", "load ysf reflector names from this YSFHosts.txt file")
+	flag.StringVar(&nxdnHostsPath, "nxdnhosts", "", "load nxdn reflector numbers from this NXDNHosts.txt file")
+	flag.StringVar(&p25HostsPath, "p25hosts", "", "load p25 reflector numbers from this P25Hosts.txt file")
+	flag.StringVar(&dcsHostsPath, "dcshosts", "", "load dcs reflector names from this DCS_Hosts.txt file")
+	flag.StringVar(&refHostsPath, "refhosts", "", "load ref reflector names from this DPlus_Hosts.txt file")
+	flag.DurationVar(&ReflectorHostsRefreshInterval, "reflectorhostsrefresh", ReflectorHostsRefreshInterval, "reload and resolve the reflector hosts files this often")
+	flag.StringVar(&echolinkNodesPath, "echolinknodes", "", "load echolink node callsigns from this node list file")
+	flag.StringVar(&allstarNodesPath, "allstarnodes", "", "load allstarlink node callsigns from this astdb.txt file")
+	flag.DurationVar(&NodesRefreshInterval, "nodesrefresh", NodesRefreshInterval, "check the node list files for changes this often")
+	flag.DurationVar(&NetworkStatusTimeout, "statustimeout", NetworkStatusTimeout, "play network status placeholders as is if the status is not available in this time")
+	flag.DurationVar(&NetworkStatusWait, "statuswait", 0, "hold playback at network status placeholders for up to this long after the request until the status is available (0 disables)")
+	flag.StringVar(&timeZone, "timezone", "", "announce the time in this time zone (e.g. Europe/Budapest), the local time zone is used if empty")
+	flag.IntVar(&NetworkMaxListedTalkgroups, "tgmaxlist", NetworkMaxListedTalkgroups, "announce only the count of talkgroup lists longer than this (0 disables)")
+	flag.StringVar(&adminAddr, "admin", "", "serve the admin api on this address (e.g. 127.0.0.1:8082)")
+	flag.StringVar(&AdminToken, "admintoken", "", "require this bearer token for admin api requests")
+	flag.DurationVar(&RegistryExpiry, "regexpiry", RegistryExpiry, "expire device registrations if they are not refreshed in this time")
+	flag.StringVar(&SchedulesPath, "schedules", "", "push the announcements scheduled in this json file to registered devices")
+	flag.StringVar(&languageFallbacks, "langfallback", "", "voice language fallback chains, like hu:de:en,de:en (all languages fall back to en at the end)")
+	flag.StringVar(&TemplatesPath, "templates", "", "build announcements from the templates in this json file")
+	flag.DurationVar(&TemplatesRefreshInterval, "templatesrefresh", TemplatesRefreshInterval, "check the templates file for changes this often")
+	flag.StringVar(&ProfilesPath, "profiles", "", "load per device announcement profiles from this json file")
+	flag.DurationVar(&ProfilesRefreshInterval, "profilesrefresh", ProfilesRefreshInterval, "check the profiles file for changes this often")
+	flag.StringVar(&healthAddr, "health", "", "serve /healthz and /readyz on this address (e.g. :8080)")
+	flag.DurationVar(&serverListGracePeriod, "readygrace", serverListGracePeriod, "report ready after this time even if the server list is empty")
 	flag.Parse()
 
+	if !CodecSetYSFMode(ysfMode) {
+		log.Fatalf("invalid ysf mode \"%s\"\n", ysfMode)
+	}
+
 	if logToFile && !silent {
 		logFile, err := os.OpenFile("spk-srv.log", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
 		if err != nil {
@@ -71,12 +98,22 @@ func main() {
 	}
 
 	log.Printf("spk-srv start, binding to %s:%d\n", bindIp, bindPort)
+	if err := CodecCheckSettings(); err != nil {
+		log.Fatal(err)
+	}
+	if adminAddr != "" {
+		if err := AdminCheckSettings(adminAddr); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	if silent {
 		log.SetFlags(0)
 		log.SetOutput(io.Discard)
 	}
 
+	AssetIndexBuild()
+
 	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{
 		IP:   net.ParseIP(bindIp),
 		Port: bindPort,
@@ -86,13 +123,122 @@ func main() {
 	}
 	defer udpConn.Close()
 
-	go BMProcess()
+	if fakeBMAddr != "" {
+		fakeBMData := bmFakeDefaultData
+		if fakeBMDataPath != "" {
+			if fakeBMData, err = BMFakeLoadData(fakeBMDataPath); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if fakeBMAddr, err = BMFakeStart(fakeBMAddr, fakeBMData); err != nil {
+			log.Fatal(err)
+		}
+
+		// Using the fake server, unless the urls were given explicitly.
+		bmAPIURLSet, serverListURLSet, dmrplusAPIURLSet := false, false, false
+		flag.Visit(func(f *flag.Flag) {
+			bmAPIURLSet = bmAPIURLSet || f.Name == "bmapi"
+			serverListURLSet = serverListURLSet || f.Name == "serverlist"
+			dmrplusAPIURLSet = dmrplusAPIURLSet || f.Name == "dmrplusapi"
+		})
+		if !bmAPIURLSet {
+			BMAPIURL = "http://" + fakeBMAddr + "/v2"
+		}
+		if !serverListURLSet {
+			NetworkServerListURL = "http://" + fakeBMAddr + "/servers.json"
+		}
+		if !dmrplusAPIURLSet {
+			DMRPlusAPIURL = "http://" + fakeBMAddr + "/dmrplus/{id}"
+		}
+	}
+
+	NetworkRegisterStatusProvider(&bmStatusProvider{})
+	if TGIFAPIURL != "" {
+		NetworkRegisterStatusProvider(&tgifStatusProvider{})
+	}
+	if FreeDMRAPIURL != "" {
+		NetworkRegisterStatusProvider(&freedmrStatusProvider{})
+	}
+	if DMRPlusAPIURL != "" {
+		NetworkRegisterStatusProvider(&dmrplusStatusProvider{})
+	}
+
+	if err := VoiceParseLanguageFallbacks(languageFallbacks); err != nil {
+		log.Fatal(err)
+	}
+
+	resolver, err := DNSNewResolver(dnsServer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	DNSSetResolver(resolver)
+
+	if timeZone != "" {
+		var err error
+		if ClockLocation, err = time.LoadLocation(timeZone); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, token := range []string{"BMSV", "HBSV", "DPSV"} {
+		PlaceholderRegisterResolver(&placeholderResolver{Token: token, Timeout: NetworkStatusTimeout, Wait: NetworkStatusWait,
+			Resolve: NetworkResolveStatusPlaceholder})
+	}
+	PlaceholderRegisterResolver(&placeholderResolver{Token: "RFSV", Immediate: true, Resolve: ReflectorHostsResolvePlaceholder})
+	PlaceholderRegisterResolver(&placeholderResolver{Token: "NOSV", Immediate: true, Resolve: NodesResolvePlaceholder})
+	PlaceholderRegisterResolver(&placeholderResolver{Token: "TISV", Immediate: true, Resolve: ClockResolvePlaceholder})
+
+	go NetworkProcess()
+	go NetworkResolveProcess()
+	go NetworkCacheProcess()
+	if TGDBPath != "" {
+		go TGDBProcess()
+	}
+	ReflectorHostsSetPath(SPK_CONNECTOR_ID_YSFREF, ysfHostsPath)
+	ReflectorHostsSetPath(SPK_CONNECTOR_ID_NXDNREF, nxdnHostsPath)
+	ReflectorHostsSetPath(SPK_CONNECTOR_ID_P25REF, p25HostsPath)
+	ReflectorHostsSetPath(SPK_CONNECTOR_ID_DCS, dcsHostsPath)
+	ReflectorHostsSetPath(SPK_CONNECTOR_ID_REF, refHostsPath)
+	if ysfHostsPath != "" || nxdnHostsPath != "" || p25HostsPath != "" || dcsHostsPath != "" || refHostsPath != "" {
+		go ReflectorHostsProcess()
+	}
+	NodesSetPath(SPK_CONNECTOR_ID_ECHOLINK, echolinkNodesPath)
+	NodesSetPath(SPK_CONNECTOR_ID_IAX2, allstarNodesPath)
+	if echolinkNodesPath != "" || allstarNodesPath != "" {
+		go NodesProcess()
+	}
+	go SchedulerProcess()
+	go RegistryProcess()
+	if adminAddr != "" {
+		go AdminProcess(adminAddr)
+	}
+	if TemplatesPath != "" {
+		go TemplatesProcess()
+	}
+	if ProfilesPath != "" {
+		go ProfilesProcess()
+	}
+	if SchedulesPath != "" {
+		go SchedulesProcess()
+	}
+
+	if healthAddr != "" {
+		go HealthProcess(healthAddr, serverListGracePeriod)
+	}
 
 	log.Println("starting listening loop")
 	buffer := make([]byte, 128)
 	for {
+		HealthUDPLoopHeartbeat()
+
+		// The read deadline makes sure the loop wakes up regularly even without incoming packets, so the health
+		// endpoint can detect a stuck loop.
+		udpConn.SetReadDeadline(time.Now().Add(healthUDPLoopWakeupInterval))
 		readBytes, fromAddr, err := udpConn.ReadFromUDP(buffer)
 		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
 			log.Fatal(err)
 		}
 
@@ -107,6 +253,10 @@ func main() {
 				v0processPacket(udpConn, fromAddr, buffer, readBytes)
 			case 1:
 				v1processPacket(udpConn, fromAddr, buffer, readBytes)
+			case 2:
+				v2processPacket(udpConn, fromAddr, buffer, readBytes)
+			case 3:
+				v3processPacket(udpConn, fromAddr, buffer, readBytes)
 			}
 		}
 	}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"net"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Describes the differences between the protocol versions. Everything else is handled by the common streaming
+// engine.
+type spkProtocol struct {
+	Version uint8
+	// Returns the asset directories for the request's voice and codec. Code pairs missing from the first directory
+	// are searched in the others in order.
+	GetVoiceDirs func(req *spkRequest, codec *spkCodec) []string
+	// Code str placeholder tokens handled for this protocol version.
+	Placeholders []string
+	// Set if the firmware using this protocol version decodes native YSF and NXDN response packets.
+	NativeCodecs bool
+}
+
+func (p *spkProtocol) encodeResponse(header *spkResponsePacketHeader, frames []byte) []byte {
+	header.Version = p.Version
+
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.BigEndian, header); err != nil {
+		log.Printf("encode answer error: %v", err)
+		return nil
+	}
+	buf.Write(frames)
+	return buf.Bytes()
+}
+
+// The .ambe assets by directory and code pair, built once as streams look up every code pair.
+var assetIndex map[string]map[string]string
+var assetIndexOnce sync.Once
+
+// Builds the asset index. Called at startup, later calls do nothing.
+func AssetIndexBuild() {
+	assetIndexOnce.Do(func() {
+		assetIndex = make(map[string]map[string]string)
+
+		// Sorting so the first of the files with the same code pair wins regardless of map order.
+		filePaths := make([]string, 0, len(_bindata))
+		for filePath := range _bindata {
+			filePaths = append(filePaths, filePath)
+		}
+		sort.Strings(filePaths)
+
+		for _, filePath := range filePaths {
+			fileName := filepath.Base(filePath)
+			if filepath.Ext(filePath) != ".ambe" || len(fileName) < 2 {
+				continue
+			}
+			dir := filepath.Dir(filePath)
+			if assetIndex[dir] == nil {
+				assetIndex[dir] = make(map[string]string)
+			}
+			// The requested code pair is stored in the first two characters of the filename.
+			if _, ok := assetIndex[dir][fileName[:2]]; !ok {
+				assetIndex[dir][fileName[:2]] = filePath
+			}
+		}
+	})
+}
+
+// Returns true if there's at least one .ambe file in the given asset directory.
+func assetDirHasFiles(dir string) bool {
+	AssetIndexBuild()
+	return len(assetIndex[filepath.Clean(dir)]) > 0
+}
+
+func getAssetPathForCodePair(dir string, codePair string) string {
+	AssetIndexBuild()
+	return assetIndex[filepath.Clean(dir)][codePair]
+}
+
+type spkAnswerStream struct {
+	protocol   *spkProtocol
+	codec      *spkCodec
+	req        *spkRequest
+	toAddr     net.UDPAddr
+	codeStr    string
+	codeStrPos int
+	reader     *bytes.Reader
+	header     spkResponsePacketHeader
+	frames     []byte
+
+	voiceDirs    []string
+	numbers      numberGrammar
+	placeholders []*placeholderPending
+	holding      bool
+}
+
+func startSendAnswer(udpConn *net.UDPConn, toAddr net.UDPAddr, protocol *spkProtocol, codec *spkCodec, req *spkRequest) {
+	s := &spkAnswerStream{
+		protocol: protocol,
+		codec:    codec,
+		req:      req,
+		toAddr:   toAddr,
+		codeStr:  req.CodeStr,
+		frames:   make([]byte, codec.FramesPerPacket*codec.FrameSize),
+	}
+	s.voiceDirs = protocol.GetVoiceDirs(req, codec)
+	if len(s.voiceDirs) > 0 {
+		s.numbers = voiceGetManifestForDir(s.voiceDirs[0]).Numbers
+	}
+
+	copy(s.header.Magic[:], SPK_PACKET_MAGIC)
+	s.header.PacketType = codec.PacketType
+	s.header.SessionID = req.SessionID
+
+	s.codeStr, s.placeholders = PlaceholderStart(s.codeStr, protocol.Placeholders, req, s.hasCodePair)
+	if s.codeStr != req.CodeStr {
+		log.Printf("code str modified for %s to %s", toAddr.String(), s.codeStr)
+	}
+
+	SchedulerAdd(udpConn, toAddr, s, SchedulerPrebufferPackets)
+}
+
+// Replaces the pending placeholders at the current position with their results, if playback reached them.
+// Returns true if the stream should be held while waiting for a result.
+func (s *spkAnswerStream) replacePendingPlaceholders() bool {
+	for len(s.placeholders) > 0 && s.placeholders[0].Pos == s.codeStrPos {
+		p := s.placeholders[0]
+		res := p.Result()
+		if res.Status == placeholderStatusPending {
+			return true
+		}
+		s.placeholders = s.placeholders[1:]
+
+		if res.Status != placeholderStatusResolved {
+			log.Printf("placeholder %s %s for %s, playing it as is", p.Token, res.Status, s.toAddr.String())
+			continue
+		}
+
+		s.replaceCodeStr(p.Pos, len(p.Token), res.CodeStr)
+	}
+	return false
+}
+
+// Replaces the given part of the code str, moving the pending placeholders after it.
+func (s *spkAnswerStream) replaceCodeStr(pos int, length int, replacement string) {
+	s.codeStr = s.codeStr[:pos] + replacement + s.codeStr[pos+length:]
+	for _, next := range s.placeholders {
+		if next.Pos > pos {
+			next.Pos += len(replacement) - length
+		}
+	}
+	log.Printf("code str modified for %s to %s", s.toAddr.String(), s.codeStr)
+}
+
+// Renders the number markup at the current position with the voice's number grammar. Returns false if there's
+// no number at the current position.
+func (s *spkAnswerStream) renderNumber() bool {
+	digits := numberParseMarkup(s.codeStr[s.codeStrPos:])
+	if digits == "" {
+		return false
+	}
+	s.replaceCodeStr(s.codeStrPos, len(digits)*2, s.numbers.codeStrForNumber(digits))
+	return true
+}
+
+func (s *spkAnswerStream) hasCodePair(codePair string) bool {
+	filePath, _ := s.getAssetPathForCodePair(codePair)
+	return filePath != ""
+}
+
+// Returns the asset for the code pair from the first voice directory which has it, and the directory's index.
+func (s *spkAnswerStream) getAssetPathForCodePair(codePair string) (string, int) {
+	for i, dir := range s.voiceDirs {
+		if filePath := getAssetPathForCodePair(dir, codePair); filePath != "" {
+			return filePath, i
+		}
+	}
+	return "", -1
+}
+
+// Opens the file for the next code char pair. Returns false if there are no more code pairs, or if the stream is
+// holding.
+func (s *spkAnswerStream) openNextCodePair() bool {
+	for s.codeStrPos < len(s.codeStr) {
+		if s.holding = s.replacePendingPlaceholders(); s.holding {
+			return false
+		}
+		if s.codeStrPos >= len(s.codeStr) {
+			break
+		}
+
+		if s.codeStrPos+2 > len(s.codeStr) {
+			log.Println("warning: last code pair is broken")
+			s.codeStrPos = len(s.codeStr)
+			break
+		}
+		if s.renderNumber() {
+			continue
+		}
+
+		var codePair = s.codeStr[s.codeStrPos : s.codeStrPos+2]
+		s.codeStrPos += 2
+
+		filePath, dirIndex := s.getAssetPathForCodePair(codePair)
+		if filePath == "" {
+			log.Printf("warning: file not found for modem mode %d code pair \"%s\", skipping\n", s.req.ModemMode, codePair)
+			continue
+		}
+		if dirIndex > 0 {
+			log.Printf("code pair \"%s\" not found in %s, using %s\n", codePair, s.voiceDirs[0], filepath.Dir(filePath))
+			VoiceCountCodePairFallback()
+		}
+
+		data, err := Asset(filePath)
+		if err != nil {
+			log.Printf("warning: can't find \"%s\", skipping\n", filePath)
+			continue
+		}
+
+		log.Printf("playing %s to %s\n", filePath, s.toAddr.String())
+
+		s.reader = bytes.NewReader(data)
+		return true
+	}
+	return false
+}
+
+func (s *spkAnswerStream) nextPacket() ([]byte, bool) {
+	// Filling up frames from the files.
+	for {
+		if s.reader == nil && !s.openNextCodePair() {
+			break
+		}
+
+		for ; int(s.header.FrameCount) < s.codec.FramesPerPacket; s.header.FrameCount++ {
+			frame := s.frames[int(s.header.FrameCount)*s.codec.FrameSize : int(s.header.FrameCount+1)*s.codec.FrameSize]
+			readBytes, err := s.reader.Read(frame)
+			if err != nil || readBytes != s.codec.FrameSize {
+				s.reader = nil
+				break
+			}
+		}
+
+		// Flushing if needed.
+		if int(s.header.FrameCount) == s.codec.FramesPerPacket {
+			data := s.protocol.encodeResponse(&s.header, s.frames)
+			s.header.SeqNum++
+			s.header.FrameCount = 0
+			return data, false
+		}
+	}
+
+	// Already read frames are kept, they are sent when the stream continues.
+	if s.holding {
+		return nil, false
+	}
+
+	s.header.PacketType = SPK_PACKET_TYPE_RESPONSE_TERMINATOR
+	return s.protocol.encodeResponse(&s.header, s.frames), true
+}
+
+func (s *spkAnswerStream) finished() {
+	RequestRemove(s.req.SessionID, &s.toAddr)
+	log.Printf("playing to %s finished\n", s.toAddr.String())
+}
+
+// Starts answering a parsed request packet of any protocol version.
+func processRequest(udpConn *net.UDPConn, fromAddr *net.UDPAddr, protocol *spkProtocol, req *spkRequest) {
+	codec := getCodecForModemMode(req.ModemMode)
+	if codec == nil {
+		log.Printf("ignoring packet, invalid modem mode %.2x\n", req.ModemMode)
+		return
+	}
+
+	if RequestIsAdded(req.SessionID, fromAddr) {
+		//log.Printf("ignoring packet, request already under processing with sid:0x%.8x\n", req.SessionID)
+		return
+	}
+	RequestAdd(req.SessionID, fromAddr)
+
+	// The firmware's possibly stale reflector and node data is replaced first, so templates get the server's.
+	var resolved bool
+	switch req.ConnectorID {
+	case SPK_CONNECTOR_ID_YSFREF, SPK_CONNECTOR_ID_NXDNREF, SPK_CONNECTOR_ID_P25REF, SPK_CONNECTOR_ID_DCS, SPK_CONNECTOR_ID_REF:
+		resolved = ReflectorHostsApply(req)
+	case SPK_CONNECTOR_ID_ECHOLINK, SPK_CONNECTOR_ID_IAX2:
+		resolved = NodesApply(req)
+	}
+	if resolved {
+		log.Printf("resolved connection for %s, code str \"%s\"\n", fromAddr.String(), req.CodeStr)
+	}
+	// Profiles are applied after templates, so device specific settings win.
+	if TemplatesApply(req) {
+		log.Printf("applied template for %s, code str \"%s\"\n", fromAddr.String(), req.CodeStr)
+	}
+	if ProfilesApply(fromAddr, req) {
+		log.Printf("applied profile for %s, code str \"%s\" voice %d\n", fromAddr.String(), req.CodeStr, req.VoiceID)
+	}
+	VoiceResolveRequest(req)
+
+	if codec = resolveCodec(protocol, req, codec); codec == nil {
+		log.Printf("ignoring packet from %s, no usable codec for modem mode %s\n", fromAddr.String(),
+			getModemModeNameStr(req.ModemMode))
+		RequestRemove(req.SessionID, fromAddr)
+		return
+	}
+
+	atStr, atdStr := decodeAnnounceTypeAndDataToStr(req.AnnounceType, req.AnnounceTypeData)
+	log.Printf("sending \"%s\" to %s (sid:0x%.8x t:%s con:%s at:%s %s)\n",
+		req.CodeStr, fromAddr.String(), req.SessionID, getModemModeNameStr(req.ModemMode),
+		getConnectorIdNameStr(req.ConnectorID), atStr, atdStr)
+	startSendAnswer(udpConn, *fromAddr, protocol, codec, req)
+}
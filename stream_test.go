@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Adds .ambe assets to the given directory for a test. Each frame is filled with its given byte, so the played
+// frames can be told apart.
+func streamTestAssets(t *testing.T, dir string, frameSize int, assets map[string][]byte) {
+	AssetIndexBuild()
+	t.Cleanup(func() {
+		for codePair := range assets {
+			delete(_bindata, filepath.Join(dir, codePair+" test.ambe"))
+		}
+		delete(assetIndex, dir)
+	})
+
+	assetIndex[dir] = make(map[string]string)
+	for codePair, frames := range assets {
+		var data []byte
+		for _, frame := range frames {
+			data = append(data, bytes.Repeat([]byte{frame}, frameSize)...)
+		}
+		filePath := filepath.Join(dir, codePair+" test.ambe")
+		_bindata[filePath] = func() (*asset, error) { return &asset{bytes: data}, nil }
+		assetIndex[dir][codePair] = filePath
+	}
+}
+
+func streamTestNew(codeStr string, dir string) *spkAnswerStream {
+	codec := spkCodecDMR
+	s := &spkAnswerStream{
+		protocol:  v2Protocol,
+		codec:     codec,
+		req:       &spkRequest{SessionID: 1, CodeStr: codeStr},
+		codeStr:   codeStr,
+		frames:    make([]byte, codec.FramesPerPacket*codec.FrameSize),
+		voiceDirs: []string{dir},
+	}
+	s.header.PacketType = codec.PacketType
+	return s
+}
+
+type streamTestPacket struct {
+	packetType spkPacketType
+	seqNum     uint8
+	// The first byte of each frame.
+	frames []byte
+}
+
+func streamTestDecode(t *testing.T, codec *spkCodec, data []byte) streamTestPacket {
+	var header spkResponsePacketHeader
+	r := bytes.NewReader(data)
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		t.Fatalf("can't decode packet header: %v", err)
+	}
+	p := streamTestPacket{packetType: header.PacketType, seqNum: header.SeqNum, frames: []byte{}}
+	frames := data[len(data)-r.Len():]
+	for i := 0; i < int(header.FrameCount); i++ {
+		p.frames = append(p.frames, frames[i*codec.FrameSize])
+	}
+	return p
+}
+
+func TestAnswerStreamPackets(t *testing.T) {
+	dir := "test-stream-voice"
+	streamTestAssets(t, dir, spkCodecDMR.FrameSize, map[string][]byte{
+		"A1": {0x11, 0x12, 0x13, 0x14},
+		"B2": {0x21, 0x22},
+		"C3": {0x31},
+	})
+	voice := spkCodecDMR.PacketType
+	term := spkPacketType(SPK_PACKET_TYPE_RESPONSE_TERMINATOR)
+
+	tests := []struct {
+		codeStr string
+		want    []streamTestPacket
+	}{
+		{"A1B2", []streamTestPacket{
+			{voice, 0, []byte{0x11, 0x12, 0x13}},
+			{voice, 1, []byte{0x14, 0x21, 0x22}},
+			{term, 2, []byte{}},
+		}},
+		{"A1C3", []streamTestPacket{
+			{voice, 0, []byte{0x11, 0x12, 0x13}},
+			{term, 1, []byte{0x14, 0x31}},
+		}},
+		// Missing code pairs are skipped.
+		{"C3ZZB2", []streamTestPacket{
+			{voice, 0, []byte{0x31, 0x21, 0x22}},
+			{term, 1, []byte{}},
+		}},
+		// The broken last code pair is dropped.
+		{"C3B", []streamTestPacket{
+			{term, 0, []byte{0x31}},
+		}},
+		{"", []streamTestPacket{
+			{term, 0, []byte{}},
+		}},
+	}
+	for _, tt := range tests {
+		s := streamTestNew(tt.codeStr, dir)
+		var got []streamTestPacket
+		for i := 0; i < 10; i++ {
+			data, last := s.nextPacket()
+			if data == nil {
+				t.Fatalf("%q: packet %d is nil", tt.codeStr, i)
+			}
+			got = append(got, streamTestDecode(t, s.codec, data))
+			if last {
+				break
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got packets %v, want %v", tt.codeStr, got, tt.want)
+		}
+	}
+}
+
+func TestAnswerStreamHoldsAtPendingPlaceholder(t *testing.T) {
+	dir := "test-stream-voice"
+	streamTestAssets(t, dir, spkCodecDMR.FrameSize, map[string][]byte{
+		"A1": {0x11, 0x12, 0x13, 0x14},
+		"B2": {0x21, 0x22},
+	})
+
+	tests := []struct {
+		name   string
+		result placeholderResult
+		want   []byte
+	}{
+		{"resolved", placeholderResult{Status: placeholderStatusResolved, CodeStr: "B2"}, []byte{0x14, 0x21, 0x22}},
+		// The token itself is played, and it has no asset.
+		{"failed", placeholderResult{Status: placeholderStatusFailed, CodeStr: "PHSV"}, []byte{0x14}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &placeholderPending{Token: "PHSV", Pos: 2, started: time.Now(), wait: time.Hour,
+				result: make(chan placeholderResult, 1)}
+			s := streamTestNew("A1PHSV", dir)
+			s.placeholders = []*placeholderPending{p}
+
+			data, last := s.nextPacket()
+			if got := streamTestDecode(t, s.codec, data); last || !reflect.DeepEqual(got.frames, []byte{0x11, 0x12, 0x13}) {
+				t.Fatalf("first packet = %v, last %v", got, last)
+			}
+
+			// Playback reached the token, the stream holds while keeping the already read frame.
+			for i := 0; i < 3; i++ {
+				if data, last := s.nextPacket(); data != nil || last {
+					t.Fatalf("holding stream returned %v, last %v", data, last)
+				}
+			}
+
+			p.result <- tt.result
+			var got []byte
+			for {
+				data, last := s.nextPacket()
+				if data == nil {
+					t.Fatal("stream still holding after the result")
+				}
+				got = append(got, streamTestDecode(t, s.codec, data).frames...)
+				if last {
+					break
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("frames after the hold = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnswerStreamThroughScheduler(t *testing.T) {
+	dir := "test-stream-voice"
+	streamTestAssets(t, dir, spkCodecDMR.FrameSize, map[string][]byte{
+		"A1": {0x11, 0x12, 0x13, 0x14, 0x15, 0x16},
+		"B2": {0x21, 0x22, 0x23},
+	})
+
+	var packets []streamTestPacket
+	schedulerTestState(t, 10)
+	schedulerSendPacket = func(udpConn *net.UDPConn, toAddr *net.UDPAddr, data []byte) {
+		packets = append(packets, streamTestDecode(t, spkCodecDMR, data))
+	}
+
+	p := &placeholderPending{Token: "PHSV", Pos: 2, started: time.Now(), wait: time.Hour,
+		result: make(chan placeholderResult, 1)}
+	s := streamTestNew("A1PHSV", dir)
+	s.placeholders = []*placeholderPending{p}
+	SchedulerAdd(nil, net.UDPAddr{}, s, 1)
+
+	tickPackets := []int{}
+	for tick := int64(11); tick <= 16; tick++ {
+		if tick == 14 {
+			p.result <- placeholderResult{Status: placeholderStatusResolved, CodeStr: "B2"}
+		}
+		before := len(packets)
+		schedulerProcessTick(tick)
+		tickPackets = append(tickPackets, len(packets)-before)
+	}
+
+	// The prebuffered packet, the second packet at tick 11, holding at ticks 12 and 13, then real time again
+	// without catching up.
+	if want := []int{1, 0, 0, 1, 1, 0}; !reflect.DeepEqual(tickPackets, want) {
+		t.Errorf("packets per tick = %v, want %v", tickPackets, want)
+	}
+	if len(packets) != 4 || packets[3].packetType != spkPacketType(SPK_PACKET_TYPE_RESPONSE_TERMINATOR) {
+		t.Fatalf("got packets %v, want 4 ending with a terminator", packets)
+	}
+	for i, packet := range packets {
+		if packet.seqNum != uint8(i) {
+			t.Errorf("packet %d has seq num %d", i, packet.seqNum)
+		}
+	}
+	if len(schedulerSessions) != 0 {
+		t.Errorf("%d sessions left, want 0", len(schedulerSessions))
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Builds the announcement for requests of an announce type, connector and language. Empty or "*" Connector and
+// Language match all. Template is a code str which can contain variables in braces, see templateVariables.
+type template struct {
+	AnnounceType string `json:"announceType"`
+	Connector    string `json:"connector"`
+	Language     string `json:"language"`
+	Template     string `json:"template"`
+}
+
+var TemplatesPath string
+var TemplatesRefreshInterval = time.Minute
+
+var templates []template
+var templatesMutex = &sync.Mutex{}
+var templatesWatch fileWatch
+
+// Variables which can be used in templates.
+var templateVariableNames = []string{"code", "id", "ip", "timeslot"}
+
+// Returns the values of the template variables for the request.
+func templateVariables(req *spkRequest) map[string]string {
+	vars := map[string]string{
+		// The code str sent by the device, so templates can augment it.
+		"code": req.CodeStr,
+		"id":   codeStrForDigits(fmt.Sprint(req.AnnounceTypeData[1])),
+	}
+
+	switch req.AnnounceType {
+	case SPK_ANNOUNCE_TYPE_CONNECTING, SPK_ANNOUNCE_TYPE_CONNECTED, SPK_ANNOUNCE_TYPE_CONNECTOR_STATUS,
+		SPK_ANNOUNCE_TYPE_CONNECTED_BRANDMEISTER_SHORTENED:
+		vars["ip"] = codeStrForText(networkGetServerIPForRequest(req), false)
+	}
+	if req.Timeslot != 0 {
+		vars["timeslot"] = codeStrForCount(int(req.Timeslot))
+	}
+	return vars
+}
+
+func templateFieldMatches(field string, value string) (bool, int) {
+	if field == "" || field == "*" {
+		return true, 0
+	}
+	return field == value, 1
+}
+
+// Returns the most specific template for the request.
+func templatesGet(req *spkRequest) (template, bool) {
+	at := getAnnounceTypeKeyStr(req.AnnounceType)
+	connector := getConnectorIdNameStr(req.ConnectorID)
+	language := VoiceGetRequestLanguage(req)
+
+	templatesMutex.Lock()
+	defer templatesMutex.Unlock()
+
+	var res template
+	bestScore := -1
+	for _, t := range templates {
+		if t.AnnounceType != at {
+			continue
+		}
+		connectorMatches, connectorScore := templateFieldMatches(t.Connector, connector)
+		languageMatches, languageScore := templateFieldMatches(t.Language, language)
+		if !connectorMatches || !languageMatches {
+			continue
+		}
+		// Connector specific templates win over language specific ones.
+		if score := connectorScore*2 + languageScore; score > bestScore {
+			res = t
+			bestScore = score
+		}
+	}
+	return res, bestScore >= 0
+}
+
+// Returns the template with its variables replaced. Unknown variables are replaced by an empty string.
+func templateExpand(t string, vars map[string]string) string {
+	var res strings.Builder
+	for {
+		start := strings.Index(t, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(t[start:], "}")
+		if end < 0 {
+			break
+		}
+		res.WriteString(t[:start])
+		res.WriteString(vars[t[start+1:start+end]])
+		t = t[start+end+1:]
+	}
+	res.WriteString(t)
+	return res.String()
+}
+
+// Returns an error if the template has unknown variables or the text around the variables is not made of code
+// pairs. Variable values are always code pairs.
+func templateCheck(t string) error {
+	for {
+		start := strings.Index(t, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(t[start:], "}")
+		if end < 0 {
+			break
+		}
+		if name := t[start+1 : start+end]; !slices.Contains(templateVariableNames, name) {
+			return fmt.Errorf("unknown variable \"%s\"", name)
+		}
+		if start%2 != 0 {
+			return errors.New("odd length code str before a variable")
+		}
+		t = t[start+end+1:]
+	}
+	if len(t)%2 != 0 {
+		return errors.New("odd length code str")
+	}
+	return nil
+}
+
+func templatesLoad(path string) ([]template, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var res []template
+	if err := json.NewDecoder(f).Decode(&res); err != nil {
+		return nil, err
+	}
+	for i, t := range res {
+		if err := templateCheck(t.Template); err != nil {
+			return nil, fmt.Errorf("template %d \"%s\": %v", i+1, t.Template, err)
+		}
+	}
+	return res, nil
+}
+
+// TemplatesApply replaces the request's code str with the one built from its template. Returns true if the
+// request has a template. Pushed requests are not changed.
+func TemplatesApply(req *spkRequest) bool {
+	if req.Pushed {
+		return false
+	}
+	t, ok := templatesGet(req)
+	if !ok {
+		return false
+	}
+	req.CodeStr = templateExpand(t.Template, templateVariables(req))
+	return true
+}
+
+// Reloads the templates if the file has been modified since the last load.
+func TemplatesUpdate() {
+	templatesWatch.update("templates", TemplatesPath, func() error {
+		newTemplates, err := templatesLoad(TemplatesPath)
+		if err != nil {
+			return err
+		}
+
+		templatesMutex.Lock()
+		templates = newTemplates
+		templatesMutex.Unlock()
+		log.Printf("loaded %d templates\n", len(newTemplates))
+		return nil
+	})
+}
+
+func TemplatesProcess() {
+	for {
+		TemplatesUpdate()
+		time.Sleep(TemplatesRefreshInterval)
+	}
+}
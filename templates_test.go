@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplateExpand(t *testing.T) {
+	vars := map[string]string{"code": "CT0102", "id": "0201"}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"CDYS", "CDYS"},
+		{"{code}", "CT0102"},
+		{"HI{code}ID{id}", "HICT0102ID0201"},
+		{"IP{ip}", "IP"},
+		{"{code", "{code"},
+		{"}{id}{", "}0201{"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := templateExpand(tt.template, vars); got != tt.want {
+			t.Errorf("templateExpand(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateCheck(t *testing.T) {
+	tests := []struct {
+		template string
+		wantErr  bool
+	}{
+		{"CDYSRFSV", false},
+		{"HI{code}ID{id}{ip}TS{timeslot}", false},
+		{"{code}", false},
+		{"CDY", true},
+		{"H{code}I", true},
+		{"HI{code}I", true},
+		{"HI{callsign}", true},
+		{"HI{code", true},
+	}
+	for _, tt := range tests {
+		if err := templateCheck(tt.template); (err != nil) != tt.wantErr {
+			t.Errorf("templateCheck(%q) = %v, want error %v", tt.template, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTemplatesLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    int
+		wantErr bool
+	}{
+		{"valid", `[{"announceType": "connected", "template": "CT{code}"}, {"announceType": "startup", "template": "ST"}]`, 2, false},
+		{"odd length", `[{"announceType": "connected", "template": "CT{code}X"}]`, 0, true},
+		{"unknown variable", `[{"announceType": "connected", "template": "{name}"}]`, 0, true},
+		{"invalid json", `[{`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "templates.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			res, err := templatesLoad(path)
+			if (err != nil) != tt.wantErr || len(res) != tt.want {
+				t.Errorf("got %d templates, error %v, want %d, error %v", len(res), err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTemplatesApply(t *testing.T) {
+	saved := templates
+	defer func() { templates = saved }()
+	templates = []template{
+		{AnnounceType: "connected", Template: "CT{code}"},
+		{AnnounceType: "connected", Language: "en", Template: "EN{code}"},
+		{AnnounceType: "connected", Connector: "ysf", Template: "YS{code}"},
+		{AnnounceType: "default", Template: "DE{code}"},
+	}
+
+	tests := []struct {
+		name        string
+		req         spkRequest
+		want        string
+		wantApplied bool
+	}{
+		{"language", spkRequest{AnnounceType: SPK_ANNOUNCE_TYPE_CONNECTED, ConnectorID: SPK_CONNECTOR_ID_HOMEBREW, CodeStr: "01"}, "EN01", true},
+		{"connector wins", spkRequest{AnnounceType: SPK_ANNOUNCE_TYPE_CONNECTED, ConnectorID: SPK_CONNECTOR_ID_YSFREF, CodeStr: "01"}, "YS01", true},
+		{"no template", spkRequest{AnnounceType: SPK_ANNOUNCE_TYPE_STARTUP, CodeStr: "ST"}, "ST", false},
+		{"pushed", spkRequest{AnnounceType: SPK_ANNOUNCE_TYPE_DEFAULT, CodeStr: "TISV", Pushed: true}, "TISV", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			if applied := TemplatesApply(&req); applied != tt.wantApplied || req.CodeStr != tt.want {
+				t.Errorf("got %q, %v, want %q, %v", req.CodeStr, applied, tt.want, tt.wantApplied)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A talkgroup database entry. Code is the talkgroup's short name as code pairs of the voice pack.
+type tgdbEntry struct {
+	Talkgroup string
+	Name      string
+	Code      string
+}
+
+var TGDBPath string
+var TGDBRefreshInterval = 10 * time.Minute
+
+var tgdbEntries = make(map[string]tgdbEntry)
+var tgdbEntriesMutex = &sync.Mutex{}
+var tgdbWatch fileWatch
+
+// Reads a CSV file with talkgroup,name,code lines. Lines starting with # are ignored.
+func tgdbReadCSV(r io.Reader) ([]tgdbEntry, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var entries []tgdbEntry
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: too few fields", len(entries)+1)
+		}
+
+		entry := tgdbEntry{Talkgroup: record[0], Name: record[1]}
+		if len(record) > 2 {
+			entry.Code = record[2]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func tgdbLoad(path string) (map[string]tgdbEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []tgdbEntry
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.NewDecoder(f).Decode(&entries)
+	} else {
+		entries, err = tgdbReadCSV(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	newEntries := make(map[string]tgdbEntry)
+	for _, entry := range entries {
+		entry.Talkgroup = strings.TrimSpace(entry.Talkgroup)
+		entry.Code = strings.TrimSpace(entry.Code)
+		if len(entry.Code)%2 != 0 {
+			log.Printf("warning: tg db entry %s has broken code \"%s\", ignoring code\n", entry.Talkgroup, entry.Code)
+			entry.Code = ""
+		}
+		newEntries[entry.Talkgroup] = entry
+	}
+	return newEntries, nil
+}
+
+// Reloads the talkgroup database if the file has been modified since the last load.
+func TGDBUpdate() {
+	tgdbWatch.update("tg db", TGDBPath, func() error {
+		newEntries, err := tgdbLoad(TGDBPath)
+		if err != nil {
+			return err
+		}
+
+		tgdbEntriesMutex.Lock()
+		tgdbEntries = newEntries
+		tgdbEntriesMutex.Unlock()
+		log.Printf("loaded %d tg db entries\n", len(newEntries))
+		return nil
+	})
+}
+
+func TGDBGetEntry(tg string) (tgdbEntry, bool) {
+	tgdbEntriesMutex.Lock()
+	defer tgdbEntriesMutex.Unlock()
+	entry, ok := tgdbEntries[tg]
+	return entry, ok
+}
+
+func TGDBProcess() {
+	for {
+		TGDBUpdate()
+		time.Sleep(TGDBRefreshInterval)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTGDBReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []tgdbEntry
+		wantErr bool
+	}{
+		{"entries", "# talkgroup,name,code\n91,World-wide,WW\n2161, Hungary\n", []tgdbEntry{
+			{Talkgroup: "91", Name: "World-wide", Code: "WW"},
+			{Talkgroup: "2161", Name: "Hungary"},
+		}, false},
+		{"quoted name", "216,\"Hungary, national\",HU\n", []tgdbEntry{{Talkgroup: "216", Name: "Hungary, national", Code: "HU"}}, false},
+		{"too few fields", "91\n", nil, true},
+		{"broken quote", "91,\"World\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tgdbReadCSV(strings.NewReader(tt.data))
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, %v, want %+v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTGDBLoad(t *testing.T) {
+	tests := []struct {
+		file string
+		data string
+	}{
+		{"tgdb.csv", "91, World-wide,WW \n9990,Parrot,PAR\n"},
+		{"tgdb.json", `[{"Talkgroup": " 91", "Name": "World-wide", "Code": "WW "}, {"Talkgroup": "9990", "Name": "Parrot", "Code": "PAR"}]`},
+	}
+	want := map[string]tgdbEntry{
+		"91": {Talkgroup: "91", Name: "World-wide", Code: "WW"},
+		// Broken codes are dropped, the talkgroup is still known.
+		"9990": {Talkgroup: "9990", Name: "Parrot"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := tgdbLoad(path)
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, %v, want %+v", got, err, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+// TGIF hotspot status API URL. {id} is replaced by the client ID, {host} by the server's host.
+var TGIFAPIURL string
+
+// Response of the TGIF hotspot status API: the talkgroup linked on each timeslot.
+type tgifHotspotStatus struct {
+	Slot1 networkTalkgroupID `json:"ts1"`
+	Slot2 networkTalkgroupID `json:"ts2"`
+}
+
+type tgifStatusProvider struct{}
+
+func (p *tgifStatusProvider) Networks() []string {
+	return []string{"TGIF"}
+}
+
+func (p *tgifStatusProvider) FetchClientData(clientId uint32, sd *networkServerData) (networkClientData, error) {
+	var result networkClientData
+	var status tgifHotspotStatus
+	if err := getJson(networkExpandURLTemplate(TGIFAPIURL, clientId, sd), &status); err != nil {
+		return result, err
+	}
+
+	// TGIF has only dynamically linked talkgroups.
+	if status.Slot1 != "" {
+		result.DynamicSubscriptions = append(result.DynamicSubscriptions, networkSubscription{Talkgroup: string(status.Slot1), Slot: 1})
+	}
+	if status.Slot2 != "" {
+		result.DynamicSubscriptions = append(result.DynamicSubscriptions, networkSubscription{Talkgroup: string(status.Slot2), Slot: 2})
+	}
+	return result, nil
+}
+
+func (p *tgifStatusProvider) GenerateNameCodeStr(hasCodePair func(codePair string) bool) string {
+	return "ATAGAIAF"
+}
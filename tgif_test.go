@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestTGIFFetchClientData(t *testing.T) {
+	sd := networkServerData{Network: "TGIF", Name: "TGIF Prime/3166", Host: "tgif.example.org"}
+	networkTestProvider(t, &tgifStatusProvider{}, &TGIFAPIURL, "/status/{id}", "/status/2161234", sd, hasAllCodePairs,
+		[]networkProviderTest{
+			{name: "both slots", response: `{"ts1": 91, "ts2": "2161"}`,
+				want:        networkClientData{DynamicSubscriptions: []networkSubscription{{"91", 1}, {"2161", 2}}},
+				wantCodeStr: "ATAGAIAF03010606" + "TS01LKDNTG#9#1" + "TS02LKDNTG#2#1#6#1"},
+			{name: "one slot", response: `{"ts1": 0, "ts2": 9990}`,
+				want:        networkClientData{DynamicSubscriptions: []networkSubscription{{"9990", 2}}},
+				wantCodeStr: "ATAGAIAF03010606" + "TS02LKDNTG#9#9#9#0"},
+			{name: "empty fields", response: `{"ts1": null, "ts2": 0}`, wantCodeStr: "ATAGAIAF03010606"},
+			{name: "missing fields", response: `{}`, wantCodeStr: "ATAGAIAF03010606"},
+			{name: "http error", wantErr: true},
+			{name: "invalid json", response: `{"ts1": `, wantErr: true},
+		})
+}
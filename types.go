@@ -10,6 +10,11 @@ const SPK_PACKET_TYPE_RESPONSE_TERMINATOR = 0
 const SPK_PACKET_TYPE_AMBE_RESPONSE = 1
 const SPK_PACKET_TYPE_REQUEST = 2
 const SPK_PACKET_TYPE_IMBE_RESPONSE = 3
+const SPK_PACKET_TYPE_YSF_DN_RESPONSE = 4
+const SPK_PACKET_TYPE_YSF_VW_RESPONSE = 5
+const SPK_PACKET_TYPE_NXDN_RESPONSE = 6
+const SPK_PACKET_TYPE_REGISTER = 7
+const SPK_PACKET_TYPE_REGISTER_ACK = 8
 
 type spkPacketType uint8
 
@@ -39,6 +44,10 @@ const SPK_VOICE_ID_FEMALE_EN = 1
 
 type spkVoiceID uint8
 
+const SPK_VOICE_GENDER_ANY = 0
+const SPK_VOICE_GENDER_MALE = 1
+const SPK_VOICE_GENDER_FEMALE = 2
+
 const SPK_CONNECTOR_ID_UNKNOWN = 0
 const SPK_CONNECTOR_ID_DMRPLUS = 1
 const SPK_CONNECTOR_ID_HOMEBREW = 2
@@ -88,33 +97,116 @@ type spkRequestPacketv1 struct {
 	CodeStr          [SPK_ANNOUNCE_DATA_MAX_LENGTH]byte
 }
 
-const SPK_AMBE_RESPONSE_PACKET_SIZE = 41
+const SPK_REQUEST_PACKET_V2_SIZE = 25 + SPK_ANNOUNCE_DATA_MAX_LENGTH
+
+type spkRequestPacketv2 struct {
+	Magic            [6]byte
+	Version          uint8
+	PacketType       spkPacketType
+	SessionID        uint32
+	ConnectorID      spkConnectorId
+	AnnounceType     spkAnnounceType
+	AnnounceTypeData [2]uint32
+	ModemMode        spkModemMode
+	VoiceID          spkVoiceID
+	Timeslot         uint8 // 0 if unknown or the device is simplex.
+	CodeStr          [SPK_ANNOUNCE_DATA_MAX_LENGTH]byte
+}
+
+const SPK_REQUEST_PACKET_V3_SIZE = 28 + SPK_ANNOUNCE_DATA_MAX_LENGTH
+
+// Version 3 selects the voice by language and gender instead of voice ID.
+type spkRequestPacketv3 struct {
+	Magic            [6]byte
+	Version          uint8
+	PacketType       spkPacketType
+	SessionID        uint32
+	ConnectorID      spkConnectorId
+	AnnounceType     spkAnnounceType
+	AnnounceTypeData [2]uint32
+	ModemMode        spkModemMode
+	VoiceID          spkVoiceID
+	Timeslot         uint8
+	Language         [2]byte // ISO 639-1 code, like "hu".
+	Gender           uint8
+	CodeStr          [SPK_ANNOUNCE_DATA_MAX_LENGTH]byte
+}
 
-type spkAMBEResponsePacket struct {
+const SPK_REGISTER_GROUPS_MAX_LENGTH = 32
+const SPK_REGISTER_PACKET_V2_SIZE = 18 + SPK_REGISTER_GROUPS_MAX_LENGTH
+
+// Sent periodically by devices which want to receive pushed announcements. Groups is a comma separated list of the
+// groups the device is a member of.
+type spkRegisterPacketv2 struct {
 	Magic      [6]byte
 	Version    uint8
 	PacketType spkPacketType
-	SessionID  uint32
-	SeqNum     uint8
-	FrameCount uint8
-	Frames     [3][9]byte
+	DeviceID   uint32
+	ModemMode  spkModemMode
+	VoiceID    spkVoiceID
+	Timeslot   uint8
+	Reserved   [3]byte
+	Groups     [SPK_REGISTER_GROUPS_MAX_LENGTH]byte
+}
+
+const SPK_REGISTER_PACKET_V3_SIZE = SPK_REGISTER_PACKET_V2_SIZE
+
+// Version 3 uses the reserved bytes of version 2 for the voice's language and gender, like in requests.
+type spkRegisterPacketv3 struct {
+	Magic      [6]byte
+	Version    uint8
+	PacketType spkPacketType
+	DeviceID   uint32
+	ModemMode  spkModemMode
+	VoiceID    spkVoiceID
+	Timeslot   uint8
+	Language   [2]byte
+	Gender     uint8
+	Groups     [SPK_REGISTER_GROUPS_MAX_LENGTH]byte
 }
 
+const SPK_REGISTER_ACK_PACKET_V2_SIZE = 14
+
+// Answer to a register packet. The device should re-register before Expiry seconds elapse.
+type spkRegisterAckPacketv2 struct {
+	Magic      [6]byte
+	Version    uint8
+	PacketType spkPacketType
+	DeviceID   uint32
+	Expiry     uint16
+}
+
+// Request fields common to all protocol versions.
+type spkRequest struct {
+	SessionID        uint32
+	ConnectorID      spkConnectorId
+	AnnounceType     spkAnnounceType
+	AnnounceTypeData [2]uint32
+	ModemMode        spkModemMode
+	VoiceID          spkVoiceID
+	Timeslot         uint8
+	// Empty if the voice is selected by VoiceID.
+	Language string
+	// "male", "female" or empty for the default gender.
+	Gender  string
+	CodeStr string
+	// Set for announcements pushed by the server. Device profiles and templates don't apply to them.
+	Pushed bool
+}
+
+const SPK_RESPONSE_PACKET_HEADER_SIZE = 14
+const SPK_AMBE_RESPONSE_PACKET_SIZE = 41
 const SPK_IMBE_RESPONSE_PACKET_SIZE = 68
 
-type spkIMBEResponsePacket struct {
+// The header is followed by the codec's frames. The frame area always has room for FramesPerPacket frames,
+// FrameCount tells how many of them are valid.
+type spkResponsePacketHeader struct {
 	Magic      [6]byte
 	Version    uint8
 	PacketType spkPacketType
 	SessionID  uint32
 	SeqNum     uint8
 	FrameCount uint8
-	Frames     [3][18]byte
-}
-
-type spkResponsePacket struct {
-	AMBE spkAMBEResponsePacket
-	IMBE spkIMBEResponsePacket
 }
 
 func getModemModeNameStr(modemMode spkModemMode) string {
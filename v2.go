@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"net"
+	"strings"
+)
+
+// Protocol version 2 adds the device's timeslot to the request, and uses the v1 voice packs.
+var v2Protocol = &spkProtocol{
+	Version:      2,
+	GetVoiceDirs: voiceGetDirs,
+	Placeholders: []string{"BMSV", "HBSV", "DPSV", "RFSV", "NOSV", "TISV"},
+	NativeCodecs: true,
+}
+
+func v2processPacket(udpConn *net.UDPConn, fromAddr *net.UDPAddr, buffer []byte, readBytes int) {
+	var packetType = buffer[7]
+
+	switch packetType {
+	default:
+		log.Printf("ignoring packet with type 0x%.2x\n", packetType)
+	case SPK_PACKET_TYPE_REGISTER:
+		if readBytes != SPK_REGISTER_PACKET_V2_SIZE {
+			log.Printf("ignoring packet with size %d\n", readBytes)
+			return
+		}
+
+		readBuf := bytes.NewReader(buffer)
+		var rp spkRegisterPacketv2
+		err := binary.Read(readBuf, binary.BigEndian, &rp)
+		if err != nil {
+			log.Println("ignoring packet, binary parse error: ", err)
+			return
+		}
+
+		registryProcessRegister(udpConn, fromAddr, &registration{
+			DeviceID:  rp.DeviceID,
+			ModemMode: rp.ModemMode,
+			VoiceID:   rp.VoiceID,
+			Timeslot:  rp.Timeslot,
+			Groups:    registryParseGroups(rp.Groups[:]),
+			protocol:  v2Protocol,
+		})
+	case SPK_PACKET_TYPE_REQUEST:
+		if readBytes != SPK_REQUEST_PACKET_V2_SIZE {
+			log.Printf("ignoring packet with size %d\n", readBytes)
+			return
+		}
+
+		// Reading the packet payload to our request struct.
+		readBuf := bytes.NewReader(buffer)
+		var rp spkRequestPacketv2
+		err := binary.Read(readBuf, binary.BigEndian, &rp)
+		if err != nil {
+			log.Println("ignoring packet, binary parse error: ", err)
+			return
+		}
+
+		rp.CodeStr[SPK_ANNOUNCE_DATA_MAX_LENGTH-1] = 0
+
+		processRequest(udpConn, fromAddr, v2Protocol, &spkRequest{
+			SessionID:        rp.SessionID,
+			ConnectorID:      rp.ConnectorID,
+			AnnounceType:     rp.AnnounceType,
+			AnnounceTypeData: rp.AnnounceTypeData,
+			ModemMode:        rp.ModemMode,
+			VoiceID:          rp.VoiceID,
+			Timeslot:         rp.Timeslot,
+			CodeStr:          strings.TrimRight(string(rp.CodeStr[:]), "\x00"),
+		})
+	}
+}
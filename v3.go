@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"net"
+	"strings"
+)
+
+// Protocol version 3 selects the voice by language and gender, falling back through the language chain.
+var v3Protocol = &spkProtocol{
+	Version:      3,
+	GetVoiceDirs: voiceGetDirs,
+	Placeholders: []string{"BMSV", "HBSV", "DPSV", "RFSV", "NOSV", "TISV"},
+	NativeCodecs: true,
+}
+
+func v3GetGenderStr(gender uint8) string {
+	switch gender {
+	case SPK_VOICE_GENDER_MALE:
+		return "male"
+	case SPK_VOICE_GENDER_FEMALE:
+		return "female"
+	default:
+		return ""
+	}
+}
+
+// Returns the lower case language code, or the default language if it's not set.
+func v3GetLanguageStr(language [2]byte) string {
+	if str := strings.ToLower(strings.TrimRight(string(language[:]), "\x00")); str != "" {
+		return str
+	}
+	return VoiceDefaultLanguage
+}
+
+func v3processPacket(udpConn *net.UDPConn, fromAddr *net.UDPAddr, buffer []byte, readBytes int) {
+	var packetType = buffer[7]
+
+	switch packetType {
+	default:
+		log.Printf("ignoring packet with type 0x%.2x\n", packetType)
+	case SPK_PACKET_TYPE_REGISTER:
+		if readBytes != SPK_REGISTER_PACKET_V3_SIZE {
+			log.Printf("ignoring packet with size %d\n", readBytes)
+			return
+		}
+
+		readBuf := bytes.NewReader(buffer)
+		var rp spkRegisterPacketv3
+		err := binary.Read(readBuf, binary.BigEndian, &rp)
+		if err != nil {
+			log.Println("ignoring packet, binary parse error: ", err)
+			return
+		}
+
+		registryProcessRegister(udpConn, fromAddr, &registration{
+			DeviceID:  rp.DeviceID,
+			ModemMode: rp.ModemMode,
+			VoiceID:   rp.VoiceID,
+			Language:  v3GetLanguageStr(rp.Language),
+			Gender:    v3GetGenderStr(rp.Gender),
+			Timeslot:  rp.Timeslot,
+			Groups:    registryParseGroups(rp.Groups[:]),
+			protocol:  v3Protocol,
+		})
+	case SPK_PACKET_TYPE_REQUEST:
+		if readBytes != SPK_REQUEST_PACKET_V3_SIZE {
+			log.Printf("ignoring packet with size %d\n", readBytes)
+			return
+		}
+
+		// Reading the packet payload to our request struct.
+		readBuf := bytes.NewReader(buffer)
+		var rp spkRequestPacketv3
+		err := binary.Read(readBuf, binary.BigEndian, &rp)
+		if err != nil {
+			log.Println("ignoring packet, binary parse error: ", err)
+			return
+		}
+
+		rp.CodeStr[SPK_ANNOUNCE_DATA_MAX_LENGTH-1] = 0
+
+		processRequest(udpConn, fromAddr, v3Protocol, &spkRequest{
+			SessionID:        rp.SessionID,
+			ConnectorID:      rp.ConnectorID,
+			AnnounceType:     rp.AnnounceType,
+			AnnounceTypeData: rp.AnnounceTypeData,
+			ModemMode:        rp.ModemMode,
+			VoiceID:          rp.VoiceID,
+			Timeslot:         rp.Timeslot,
+			Language:         v3GetLanguageStr(rp.Language),
+			Gender:           v3GetGenderStr(rp.Gender),
+			CodeStr:          strings.TrimRight(string(rp.CodeStr[:]), "\x00"),
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A phrase of a voice pack: the code pair, the text to say and the silence around it.
+type voiceBuildPhrase struct {
+	Code        string
+	Text        string
+	PreSilence  time.Duration
+	PostSilence time.Duration
+}
+
+type voiceBuildConfig struct {
+	PhrasesPath string
+	OutDir      string
+	// Command templates, see voiceBuildRunCommand. Encoders are keyed by codec directory name.
+	TTSCommand   string
+	Encoders     map[string]string
+	Language     string
+	Gender       string
+	ManifestPath string
+	// Sample rate of the encoders' input.
+	SampleRate int
+	Trim       time.Duration
+	Gain       float64
+	// Gain of the limiter above its threshold, see wavAudio.gain.
+	Limiter float64
+	Jobs    int
+}
+
+// Collects repeated -encoder codec=command flags.
+type voiceBuildEncoderFlag map[string]string
+
+func (f voiceBuildEncoderFlag) String() string {
+	return fmt.Sprint(map[string]string(f))
+}
+
+func (f voiceBuildEncoderFlag) Set(value string) error {
+	codec, command, ok := strings.Cut(value, "=")
+	if !ok || codec == "" || command == "" {
+		return errors.New("encoder must be given as codec=command")
+	}
+	f[codec] = command
+	return nil
+}
+
+// Splits a command line to arguments. Arguments can be quoted with single or double quotes.
+func voiceBuildSplitArgs(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, c := range s {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// Parses a phrase list. Each line is a code pair, the text, and optionally the pre- and post-silence in seconds,
+// like `CT "connected to" 0 0.2`. Empty lines and lines starting with # are skipped.
+func voiceBuildParsePhrases(r io.Reader) ([]voiceBuildPhrase, error) {
+	var phrases []voiceBuildPhrase
+
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields, err := voiceBuildSplitArgs(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		if len(fields) < 2 || len(fields) > 4 || len(fields[0]) != 2 {
+			return nil, fmt.Errorf("line %d: expected code pair, text and optional pre- and post-silence", lineNum)
+		}
+
+		p := voiceBuildPhrase{Code: fields[0], Text: fields[1]}
+		for i, silence := range []*time.Duration{&p.PreSilence, &p.PostSilence} {
+			if len(fields) <= i+2 {
+				break
+			}
+			seconds, err := strconv.ParseFloat(fields[i+2], 64)
+			if err != nil || seconds < 0 {
+				return nil, fmt.Errorf("line %d: invalid silence \"%s\"", lineNum, fields[i+2])
+			}
+			*silence = time.Duration(seconds * float64(time.Second))
+		}
+		phrases = append(phrases, p)
+	}
+	return phrases, scanner.Err()
+}
+
+// Runs a command template. Variables in braces like {text} are replaced in each argument, the command is not run
+// by a shell. stdin is written to the command's standard input.
+func voiceBuildRunCommand(template string, vars map[string]string, stdin string) error {
+	args, err := voiceBuildSplitArgs(template)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New("empty command")
+	}
+	for i := range args {
+		args[i] = templateExpand(args[i], vars)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(stdin)
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if output := strings.TrimSpace(string(output)); output != "" {
+		return fmt.Errorf("%s: %v: %s", args[0], err, output)
+	}
+	return fmt.Errorf("%s: %v", args[0], err)
+}
+
+// Returns the file name of the phrase's assets without extension, like "CT connected to".
+func voiceBuildGetFileName(p voiceBuildPhrase) string {
+	return p.Code + " " + strings.ReplaceAll(p.Text, "/", "-")
+}
+
+// Generates the phrase with the tts, processes it and encodes it with all encoders.
+func voiceBuildPhraseAssets(cfg *voiceBuildConfig, p voiceBuildPhrase) error {
+	tmpDir, err := os.MkdirTemp("", "spk-voice-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fileName := voiceBuildGetFileName(p)
+	vars := map[string]string{
+		"code":     p.Code,
+		"text":     p.Text,
+		"language": cfg.Language,
+		"gender":   cfg.Gender,
+		"out":      filepath.Join(tmpDir, "tts.wav"),
+	}
+	if err := voiceBuildRunCommand(cfg.TTSCommand, vars, p.Text); err != nil {
+		return fmt.Errorf("tts: %v", err)
+	}
+
+	a, err := wavRead(vars["out"])
+	if err != nil {
+		return fmt.Errorf("tts output: %v", err)
+	}
+	a.trim(cfg.Trim)
+	a.gain(cfg.Gain, cfg.Limiter)
+	a.pad(p.PreSilence, p.PostSilence)
+
+	// The processed wav is kept in the pack, so it can be encoded again without the tts.
+	wavPath := filepath.Join(cfg.OutDir, "uncompressed", fileName+".wav")
+	if err := wavWrite(wavPath, a); err != nil {
+		return err
+	}
+
+	a.resample(cfg.SampleRate)
+	rawPath := filepath.Join(tmpDir, "encoder.raw")
+	if err := os.WriteFile(rawPath, a.rawBigEndian(), 0644); err != nil {
+		return err
+	}
+
+	for codec, command := range cfg.Encoders {
+		vars := map[string]string{
+			"in":   rawPath,
+			"wav":  wavPath,
+			"rate": strconv.Itoa(cfg.SampleRate),
+			"out":  filepath.Join(cfg.OutDir, codec, fileName+".ambe"),
+		}
+		if err := voiceBuildRunCommand(command, vars, ""); err != nil {
+			return fmt.Errorf("%s encoder: %v", codec, err)
+		}
+	}
+	return nil
+}
+
+// Writes the pack's manifest with the rules of the given manifest and the phrases.
+func voiceBuildWriteManifest(cfg *voiceBuildConfig, phrases []voiceBuildPhrase) error {
+	var m voiceManifest
+	if cfg.ManifestPath != "" {
+		data, err := os.ReadFile(cfg.ManifestPath)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("%s: %v", cfg.ManifestPath, err)
+		}
+	}
+
+	m.Language = cfg.Language
+	m.Gender = cfg.Gender
+	m.Phrases = make(map[string]string)
+	for _, p := range phrases {
+		m.Phrases[p.Code] = p.Text
+	}
+
+	data, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(cfg.OutDir, "manifest.json"), append(data, '\n'), 0644)
+}
+
+// VoicesBuild builds a voice pack from a phrase list. The manifest is written only if all phrases succeed.
+func VoicesBuild(cfg *voiceBuildConfig) error {
+	f, err := os.Open(cfg.PhrasesPath)
+	if err != nil {
+		return err
+	}
+	phrases, err := voiceBuildParsePhrases(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %v", cfg.PhrasesPath, err)
+	}
+
+	dirs := []string{filepath.Join(cfg.OutDir, "uncompressed")}
+	for codec := range cfg.Encoders {
+		dirs = append(dirs, filepath.Join(cfg.OutDir, codec))
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("building %d phrases to %s\n", len(phrases), cfg.OutDir)
+
+	jobs := make(chan voiceBuildPhrase)
+	var wg sync.WaitGroup
+	var failedMutex sync.Mutex
+	var failed []string
+	for i := 0; i < max(cfg.Jobs, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				log.Printf("generating %s\n", voiceBuildGetFileName(p))
+				if err := voiceBuildPhraseAssets(cfg, p); err != nil {
+					log.Printf("error generating %s: %v\n", voiceBuildGetFileName(p), err)
+					failedMutex.Lock()
+					failed = append(failed, p.Code)
+					failedMutex.Unlock()
+				}
+			}
+		}()
+	}
+	for _, p := range phrases {
+		jobs <- p
+	}
+	close(jobs)
+	wg.Wait()
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("%d phrases failed: %s", len(failed), strings.Join(failed, " "))
+	}
+	return voiceBuildWriteManifest(cfg, phrases)
+}
+
+// VoicesCommand runs the voices subcommand with the given arguments and returns the exit code.
+func VoicesCommand(args []string) int {
+	if len(args) == 0 || args[0] != "build" {
+		fmt.Fprintln(os.Stderr, "usage: spk-srv voices build [flags]")
+		return 2
+	}
+
+	cfg := voiceBuildConfig{Encoders: make(map[string]string)}
+	fs := flag.NewFlagSet("voices build", flag.ExitOnError)
+	fs.StringVar(&cfg.PhrasesPath, "phrases", "", "read the phrases from this file")
+	fs.StringVar(&cfg.OutDir, "out", "", "write the voice pack to this directory (e.g. voices/v1/srf-male-de)")
+	fs.StringVar(&cfg.TTSCommand, "tts", "", "tts command, {text} is replaced by the text (also given on stdin), {out} by the wav file to write")
+	fs.Var(voiceBuildEncoderFlag(cfg.Encoders), "encoder", "encoder command for a codec directory as codec=command, {in} is replaced by the raw big endian pcm input, {out} by the .ambe file to write (can be repeated)")
+	fs.StringVar(&cfg.Language, "language", "", "language of the pack, taken from the output directory name if empty")
+	fs.StringVar(&cfg.Gender, "gender", "", "gender of the pack, taken from the output directory name if empty")
+	fs.StringVar(&cfg.ManifestPath, "manifest", "", "take the number and time rules of the pack from this manifest json file")
+	fs.IntVar(&cfg.SampleRate, "rate", 8000, "sample rate of the encoders' input")
+	fs.DurationVar(&cfg.Trim, "trim", 100*time.Millisecond, "cut this much from both ends of the tts output")
+	fs.Float64Var(&cfg.Gain, "gain", 7, "change the volume of the tts output by this many dB")
+	fs.Float64Var(&cfg.Limiter, "limiter", 0.1, "limit peaks like sox's vol effect with this limiter gain, 0 clips them")
+	fs.IntVar(&cfg.Jobs, "jobs", 1, "generate this many phrases in parallel (hardware encoders usually need 1)")
+	fs.Parse(args[1:])
+
+	if cfg.PhrasesPath == "" || cfg.OutDir == "" || cfg.TTSCommand == "" || len(cfg.Encoders) == 0 {
+		fmt.Fprintln(os.Stderr, "-phrases, -out, -tts and at least one -encoder are needed")
+		fs.Usage()
+		return 2
+	}
+	if parts := strings.Split(filepath.Base(filepath.Clean(cfg.OutDir)), "-"); len(parts) == 3 {
+		if cfg.Gender == "" {
+			cfg.Gender = parts[1]
+		}
+		if cfg.Language == "" {
+			cfg.Language = parts[2]
+		}
+	}
+
+	if err := VoicesBuild(&cfg); err != nil {
+		log.Println("voice pack build error: ", err)
+		return 1
+	}
+	log.Printf("voice pack written to %s\n", cfg.OutDir)
+	return 0
+}
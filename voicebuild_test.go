@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVoiceBuildSplitArgs(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []string
+		wantErr bool
+	}{
+		{"text2wave -o {out}", []string{"text2wave", "-o", "{out}"}, false},
+		{`sh -c 'cp "$0" "$1"' {in}`, []string{"sh", "-c", `cp "$0" "$1"`, "{in}"}, false},
+		{`CT "connected to"  0	0.2`, []string{"CT", "connected to", "0", "0.2"}, false},
+		{`a""b ''`, []string{"ab", ""}, false},
+		{"", nil, false},
+		{`a "b`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := voiceBuildSplitArgs(tt.s)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("voiceBuildSplitArgs(%q) = %q, %v, want %q, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestVoiceBuildParsePhrases(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []voiceBuildPhrase
+		wantErr bool
+	}{
+		{"phrases", "# comment\n\nPA alpha\nCT \"connected to\" 0 0.2\nTI time 0.5\n", []voiceBuildPhrase{
+			{Code: "PA", Text: "alpha"},
+			{Code: "CT", Text: "connected to", PostSilence: 200 * time.Millisecond},
+			{Code: "TI", Text: "time", PreSilence: 500 * time.Millisecond},
+		}, false},
+		{"long code", "PAA alpha\n", nil, true},
+		{"no text", "PA\n", nil, true},
+		{"too many fields", "PA alpha 0 0 0\n", nil, true},
+		{"invalid silence", "PA alpha x\n", nil, true},
+		{"negative silence", "PA alpha -1\n", nil, true},
+		{"unterminated quote", "PA \"alpha\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := voiceBuildParsePhrases(strings.NewReader(tt.data))
+			if (err != nil) != tt.wantErr || (!tt.wantErr && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("got %+v, %v, want %+v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Returns a build config with a stub tts copying a one second sample, which fails for the failCode phrase, and
+// stub encoders copying their raw input.
+func voiceBuildTestConfig(t *testing.T, phrases string, failCode string) *voiceBuildConfig {
+	dir := t.TempDir()
+	samplePath := filepath.Join(dir, "sample.wav")
+	if err := wavWrite(samplePath, &wavAudio{SampleRate: 16000, Samples: make([]int16, 16000)}); err != nil {
+		t.Fatal(err)
+	}
+	phrasesPath := filepath.Join(dir, "phrases.txt")
+	if err := os.WriteFile(phrasesPath, []byte(phrases), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	encoder := `sh -c 'cp "$0" "$1"' {in} {out}`
+	return &voiceBuildConfig{
+		PhrasesPath: phrasesPath,
+		OutDir:      filepath.Join(dir, "srf-male-xx"),
+		TTSCommand:  `sh -c 'test "$0" != "` + failCode + `" && cp "$1" "$2"' {code} ` + samplePath + ` {out}`,
+		Encoders:    map[string]string{"dmr": encoder, "dstar": encoder},
+		Language:    "xx",
+		Gender:      "male",
+		SampleRate:  8000,
+		Gain:        7,
+		Limiter:     0.1,
+		Jobs:        2,
+	}
+}
+
+func TestVoicesBuild(t *testing.T) {
+	cfg := voiceBuildTestConfig(t, "PA alpha\nCT \"connected to\" 0.1 0.2\n", "")
+	if err := VoicesBuild(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, seconds := range map[string]float64{"PA alpha": 1, "CT connected to": 1.3} {
+		a, err := wavRead(filepath.Join(cfg.OutDir, "uncompressed", name+".wav"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := int(seconds * 16000); a.SampleRate != 16000 || len(a.Samples) != want {
+			t.Errorf("%s.wav has %d samples at %d Hz, want %d at 16000 Hz", name, len(a.Samples), a.SampleRate, want)
+		}
+
+		// The stub encoders copy the raw 8 kHz input.
+		for _, codec := range []string{"dmr", "dstar"} {
+			data, err := os.ReadFile(filepath.Join(cfg.OutDir, codec, name+".ambe"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := int(seconds*8000) * 2; len(data) != want {
+				t.Errorf("%s/%s.ambe has %d bytes, want %d", codec, name, len(data), want)
+			}
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(cfg.OutDir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m voiceManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	want := voiceManifest{Language: "xx", Gender: "male", Phrases: map[string]string{"PA": "alpha", "CT": "connected to"}}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("manifest %+v, want %+v", m, want)
+	}
+}
+
+func TestVoicesBuildFailedPhrase(t *testing.T) {
+	cfg := voiceBuildTestConfig(t, "PA alpha\nPB bravo\nPC charlie\n", "PB")
+	err := VoicesBuild(cfg)
+	if err == nil || !strings.Contains(err.Error(), "1 phrases failed: PB") {
+		t.Errorf("got error %v, want the failed phrase", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.OutDir, "manifest.json")); !os.IsNotExist(err) {
+		t.Error("manifest written after a failed phrase")
+	}
+	if _, err := os.Stat(filepath.Join(cfg.OutDir, "dmr", "PC charlie.ambe")); err != nil {
+		t.Error("other phrases not built: ", err)
+	}
+}
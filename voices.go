@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A v1 voice pack, named like srf-<gender>-<language>.
+type voicePack struct {
+	Name     string
+	Language string
+	Gender   string
+	Manifest voiceManifest
+}
+
+// The optional manifest.json of a voice pack, describing the rules of its language.
+type voiceManifest struct {
+	Numbers numberGrammar `json:"numbers,omitempty"`
+	// Code str of time announcements with {hour}, {minute} and {ampm} variables. {minute} is empty on the hour.
+	// Empty for the default English format.
+	Time string `json:"time,omitempty"`
+	// "12h" or "24h" hours in time announcements.
+	Clock string `json:"clock,omitempty"`
+
+	// Written by spk-srv voices build for reference, the server takes the pack's language and gender from its name.
+	Language string            `json:"language,omitempty"`
+	Gender   string            `json:"gender,omitempty"`
+	Phrases  map[string]string `json:"phrases,omitempty"`
+}
+
+type voiceStats struct {
+	UnknownVoiceIDs   int64 `json:"unknownVoiceIds"`
+	LanguageFallbacks int64 `json:"languageFallbacks"`
+	CodePairFallbacks int64 `json:"codePairFallbacks"`
+}
+
+const voicePacksDir = "voices/v1/"
+
+// Every language falls back to this at the end of its chain.
+var VoiceDefaultLanguage = "en"
+
+// Used for unknown voice IDs and requests without a gender.
+var VoiceDefaultGender = "male"
+
+// Fallback languages of each language, in order.
+var VoiceLanguageFallbacks = make(map[string][]string)
+
+var voicePacks []voicePack
+var voicePacksOnce sync.Once
+
+var voiceUnknownVoiceIDs atomic.Int64
+var voiceLanguageFallbacks atomic.Int64
+var voiceCodePairFallbacks atomic.Int64
+
+// Returns the voice packs found in the assets.
+func voiceGetPacks() []voicePack {
+	voicePacksOnce.Do(func() {
+		names := make(map[string]bool)
+		for filePath := range _bindata {
+			if rest, ok := strings.CutPrefix(filePath, voicePacksDir); ok {
+				if name, _, ok := strings.Cut(rest, "/"); ok {
+					names[name] = true
+				}
+			}
+		}
+
+		for name := range names {
+			parts := strings.Split(name, "-")
+			if len(parts) != 3 {
+				log.Printf("warning: ignoring voice pack with unknown name format %s\n", name)
+				continue
+			}
+			p := voicePack{Name: name, Gender: parts[1], Language: parts[2]}
+			p.Manifest = voiceLoadManifest(name)
+			voicePacks = append(voicePacks, p)
+		}
+		// Sorting so selection doesn't depend on map order.
+		slices.SortFunc(voicePacks, func(a, b voicePack) int { return strings.Compare(a.Name, b.Name) })
+	})
+	return voicePacks
+}
+
+// Loads the manifest of the voice pack. Packs without one use the default rules.
+func voiceLoadManifest(name string) voiceManifest {
+	var m voiceManifest
+
+	data, err := Asset(voicePacksDir + name + "/manifest.json")
+	if err != nil {
+		return m
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		log.Printf("warning: can't parse manifest of voice pack %s: %v\n", name, err)
+		return voiceManifest{}
+	}
+	return m
+}
+
+// Parses language fallback chains like "hu:de:en,de:en", where hu falls back to de, then en.
+func VoiceParseLanguageFallbacks(spec string) error {
+	for _, chain := range strings.Split(spec, ",") {
+		if chain = strings.TrimSpace(chain); chain == "" {
+			continue
+		}
+		languages := strings.Split(chain, ":")
+		if len(languages) < 2 {
+			return fmt.Errorf("invalid language fallback chain \"%s\"", chain)
+		}
+		VoiceLanguageFallbacks[languages[0]] = languages[1:]
+	}
+	return nil
+}
+
+// Returns the language and its fallback languages in order.
+func voiceGetLanguageChain(language string) []string {
+	chain := append([]string{language}, VoiceLanguageFallbacks[language]...)
+	chain = append(chain, VoiceDefaultLanguage)
+
+	var res []string
+	for _, l := range chain {
+		if !slices.Contains(res, l) {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+// Returns the language and gender of a v1 voice ID.
+func voiceGetLanguageAndGender(voiceID spkVoiceID) (string, string, bool) {
+	switch voiceID {
+	case SPK_VOICE_ID_MALE_EN:
+		return "en", "male", true
+	case SPK_VOICE_ID_FEMALE_EN:
+		return "en", "female", true
+	}
+	return VoiceDefaultLanguage, VoiceDefaultGender, false
+}
+
+// Returns the language of the request's voice.
+func VoiceGetRequestLanguage(req *spkRequest) string {
+	if req.Language != "" {
+		return req.Language
+	}
+	language, _, _ := voiceGetLanguageAndGender(req.VoiceID)
+	return language
+}
+
+// Returns the voice packs to use for the language and gender, in fallback order. All packs have the same gender,
+// so an announcement doesn't switch voices: the requested one if the first language of the chain with packs has it,
+// otherwise the default gender or the first gender that language has.
+func voiceSelectPacks(language string, gender string) []voicePack {
+	chain := voiceGetLanguageChain(language)
+	if gender == "" {
+		gender = VoiceDefaultGender
+	}
+
+	var genders []string
+	for _, l := range chain {
+		for _, p := range voiceGetPacks() {
+			if p.Language == l {
+				genders = append(genders, p.Gender)
+			}
+		}
+		if len(genders) > 0 {
+			break
+		}
+	}
+	switch {
+	case len(genders) == 0:
+		return nil
+	case !slices.Contains(genders, gender) && slices.Contains(genders, VoiceDefaultGender):
+		gender = VoiceDefaultGender
+	case !slices.Contains(genders, gender):
+		gender = genders[0]
+	}
+
+	var res []voicePack
+	for _, l := range chain {
+		for _, p := range voiceGetPacks() {
+			if p.Language == l && p.Gender == gender {
+				res = append(res, p)
+			}
+		}
+	}
+	return res
+}
+
+// VoiceResolveRequest sets the request's language and gender from its voice ID if they are not set, and reports
+// if the language has no voice pack.
+func VoiceResolveRequest(req *spkRequest) {
+	if req.Language == "" {
+		var known bool
+		if req.Language, req.Gender, known = voiceGetLanguageAndGender(req.VoiceID); !known {
+			log.Printf("unknown voice id %d, using %s voice\n", req.VoiceID, req.Language)
+			voiceUnknownVoiceIDs.Add(1)
+		}
+	}
+
+	if packs := voiceSelectPacks(req.Language, req.Gender); len(packs) > 0 && packs[0].Language != req.Language {
+		log.Printf("no %s voice, falling back to %s\n", req.Language, packs[0].Language)
+		voiceLanguageFallbacks.Add(1)
+	}
+}
+
+// Returns the voice packs for the request, in fallback order.
+func voiceSelectRequestPacks(req *spkRequest) []voicePack {
+	language, gender := req.Language, req.Gender
+	if language == "" {
+		language, gender, _ = voiceGetLanguageAndGender(req.VoiceID)
+	}
+	return voiceSelectPacks(language, gender)
+}
+
+// Returns the asset directories for the request's voice and codec, in fallback order.
+func voiceGetDirs(req *spkRequest, codec *spkCodec) []string {
+	var dirs []string
+	for _, p := range voiceSelectRequestPacks(req) {
+		dirs = append(dirs, voicePacksDir+p.Name+"/"+codec.Dir+"/")
+	}
+	return dirs
+}
+
+// Returns the manifest of the first voice pack selected for the request. The rules of fallback packs are not
+// used, as playback continues in the selected pack's language.
+func VoiceGetManifest(req *spkRequest) voiceManifest {
+	if packs := voiceSelectRequestPacks(req); len(packs) > 0 {
+		return packs[0].Manifest
+	}
+	return voiceManifest{}
+}
+
+// Returns the manifest of the voice pack of an asset directory.
+func voiceGetManifestForDir(dir string) voiceManifest {
+	name, _, _ := strings.Cut(strings.TrimPrefix(dir, voicePacksDir), "/")
+	for _, p := range voiceGetPacks() {
+		if strings.HasPrefix(dir, voicePacksDir) && p.Name == name {
+			return p.Manifest
+		}
+	}
+	return voiceManifest{}
+}
+
+// Called when a code pair is played from a fallback voice.
+func VoiceCountCodePairFallback() {
+	voiceCodePairFallbacks.Add(1)
+}
+
+func VoiceGetStats() voiceStats {
+	return voiceStats{
+		UnknownVoiceIDs:   voiceUnknownVoiceIDs.Load(),
+		LanguageFallbacks: voiceLanguageFallbacks.Load(),
+		CodePairFallbacks: voiceCodePairFallbacks.Load(),
+	}
+}
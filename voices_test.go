@@ -0,0 +1,118 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Replaces the voice packs found in the assets for a test.
+func voiceTestPacks(t *testing.T, names ...string) {
+	saved := voiceGetPacks()
+	t.Cleanup(func() { voicePacks = saved })
+
+	voicePacks = nil
+	for _, name := range names {
+		parts := strings.Split(name, "-")
+		voicePacks = append(voicePacks, voicePack{Name: name, Gender: parts[1], Language: parts[2]})
+	}
+}
+
+func TestVoiceParseLanguageFallbacks(t *testing.T) {
+	saved := VoiceLanguageFallbacks
+	defer func() { VoiceLanguageFallbacks = saved }()
+
+	tests := []struct {
+		spec    string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{"hu:de:en,de:en", map[string][]string{"hu": {"de", "en"}, "de": {"en"}}, false},
+		{" hu:de , ", map[string][]string{"hu": {"de"}}, false},
+		{"", map[string][]string{}, false},
+		{"hu", map[string][]string{}, true},
+	}
+	for _, tt := range tests {
+		VoiceLanguageFallbacks = make(map[string][]string)
+		err := VoiceParseLanguageFallbacks(tt.spec)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(VoiceLanguageFallbacks, tt.want) {
+			t.Errorf("VoiceParseLanguageFallbacks(%q) = %v, %v, want %v, error %v", tt.spec, VoiceLanguageFallbacks, err,
+				tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestVoiceGetLanguageChain(t *testing.T) {
+	saved := VoiceLanguageFallbacks
+	defer func() { VoiceLanguageFallbacks = saved }()
+	VoiceLanguageFallbacks = map[string][]string{"hu": {"de", "en"}, "at": {"de"}}
+
+	tests := []struct {
+		language string
+		want     []string
+	}{
+		{"hu", []string{"hu", "de", "en"}},
+		{"at", []string{"at", "de", "en"}},
+		{"fr", []string{"fr", "en"}},
+		{"en", []string{"en"}},
+	}
+	for _, tt := range tests {
+		if got := voiceGetLanguageChain(tt.language); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("voiceGetLanguageChain(%q) = %v, want %v", tt.language, got, tt.want)
+		}
+	}
+}
+
+func TestVoiceSelectPacks(t *testing.T) {
+	voiceTestPacks(t, "srf-female-en", "srf-male-en", "srf-female-hu", "srf-male-de")
+	saved := VoiceLanguageFallbacks
+	defer func() { VoiceLanguageFallbacks = saved }()
+	VoiceLanguageFallbacks = map[string][]string{"hu": {"de"}}
+
+	tests := []struct {
+		language, gender string
+		want             []string
+	}{
+		{"en", "female", []string{"srf-female-en"}},
+		{"en", "male", []string{"srf-male-en"}},
+		{"en", "", []string{"srf-male-en"}},
+		{"hu", "female", []string{"srf-female-hu", "srf-female-en"}},
+		// The first language only has a female voice, fallbacks stay female instead of using the male de pack.
+		{"hu", "male", []string{"srf-female-hu", "srf-female-en"}},
+		{"de", "female", []string{"srf-male-de", "srf-male-en"}},
+		{"fr", "female", []string{"srf-female-en"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, p := range voiceSelectPacks(tt.language, tt.gender) {
+			got = append(got, p.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("voiceSelectPacks(%q, %q) = %v, want %v", tt.language, tt.gender, got, tt.want)
+		}
+	}
+}
+
+func TestVoiceResolveRequest(t *testing.T) {
+	voiceTestPacks(t, "srf-female-en", "srf-male-en")
+
+	tests := []struct {
+		req              spkRequest
+		language, gender string
+	}{
+		{spkRequest{VoiceID: SPK_VOICE_ID_FEMALE_EN}, "en", "female"},
+		{spkRequest{VoiceID: SPK_VOICE_ID_MALE_EN}, "en", "male"},
+		{spkRequest{VoiceID: 200}, "en", "male"},
+		{spkRequest{VoiceID: SPK_VOICE_ID_FEMALE_EN, Language: "hu", Gender: "female"}, "hu", "female"},
+	}
+	for _, tt := range tests {
+		req := tt.req
+		VoiceResolveRequest(&req)
+		if req.Language != tt.language || req.Gender != tt.gender {
+			t.Errorf("voice %d resolved to %s %s, want %s %s", tt.req.VoiceID, req.Language, req.Gender, tt.language, tt.gender)
+		}
+		if packs := voiceSelectRequestPacks(&req); len(packs) == 0 || packs[0].Gender != tt.gender {
+			t.Errorf("voice %d selected %v", tt.req.VoiceID, packs)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// Mono 16-bit PCM audio.
+type wavAudio struct {
+	SampleRate int
+	Samples    []int16
+}
+
+type wavFormatChunk struct {
+	AudioFormat   uint16
+	Channels      uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+}
+
+// Reads a 16-bit PCM wav file. Multiple channels are mixed down to mono.
+func wavRead(path string) (*wavAudio, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, errors.New("not a wav file")
+	}
+
+	var format *wavFormatChunk
+	r := bytes.NewReader(data[12:])
+	for {
+		var chunkID [4]byte
+		var chunkSize uint32
+		if err := binary.Read(r, binary.LittleEndian, &chunkID); err != nil {
+			return nil, errors.New("no data chunk")
+		}
+		if err := binary.Read(r, binary.LittleEndian, &chunkSize); err != nil {
+			return nil, err
+		}
+		// Some tools write the streaming size 0xffffffff for the last chunk, the allocation is capped at the
+		// bytes left in the file.
+		chunk := make([]byte, min(int64(chunkSize), int64(r.Len())))
+		n, err := io.ReadFull(r, chunk)
+		if err == nil && int64(n) < int64(chunkSize) {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil && string(chunkID[:]) != "data" {
+			return nil, err
+		}
+		chunk = chunk[:n]
+		if chunkSize%2 == 1 {
+			r.ReadByte()
+		}
+
+		switch string(chunkID[:]) {
+		case "fmt ":
+			format = &wavFormatChunk{}
+			if err := binary.Read(bytes.NewReader(chunk), binary.LittleEndian, format); err != nil {
+				return nil, err
+			}
+			if format.AudioFormat != 1 || format.BitsPerSample != 16 || format.Channels == 0 {
+				return nil, fmt.Errorf("unsupported wav format %d with %d bits per sample, only 16-bit pcm is supported",
+					format.AudioFormat, format.BitsPerSample)
+			}
+		case "data":
+			if format == nil {
+				return nil, errors.New("data chunk before fmt chunk")
+			}
+			channels := int(format.Channels)
+			a := &wavAudio{SampleRate: int(format.SampleRate), Samples: make([]int16, len(chunk)/2/channels)}
+			for i := range a.Samples {
+				var sum int
+				for c := 0; c < channels; c++ {
+					sum += int(int16(binary.LittleEndian.Uint16(chunk[(i*channels+c)*2:])))
+				}
+				a.Samples[i] = int16(sum / channels)
+			}
+			return a, nil
+		}
+	}
+}
+
+func wavWrite(path string, a *wavAudio) error {
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(a.Samples)*2))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, wavFormatChunk{
+		AudioFormat:   1,
+		Channels:      1,
+		SampleRate:    uint32(a.SampleRate),
+		ByteRate:      uint32(a.SampleRate * 2),
+		BlockAlign:    2,
+		BitsPerSample: 16,
+	})
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(a.Samples)*2))
+	binary.Write(&buf, binary.LittleEndian, a.Samples)
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// Returns the samples as raw big endian 16-bit pcm.
+func (a *wavAudio) rawBigEndian() []byte {
+	res := make([]byte, len(a.Samples)*2)
+	for i, s := range a.Samples {
+		binary.BigEndian.PutUint16(res[i*2:], uint16(s))
+	}
+	return res
+}
+
+func (a *wavAudio) sampleCount(d time.Duration) int {
+	return int(d.Seconds() * float64(a.SampleRate))
+}
+
+// Cuts d from both ends.
+func (a *wavAudio) trim(d time.Duration) {
+	n := a.sampleCount(d)
+	if 2*n >= len(a.Samples) {
+		a.Samples = nil
+		return
+	}
+	a.Samples = a.Samples[n : len(a.Samples)-n]
+}
+
+// Changes the volume by the given dB. Like the limiter of sox's vol effect, if limiterGain is not 0 and the volume
+// is increased, samples above a threshold are amplified by limiterGain instead, so peaks reach full scale without
+// clipping. Otherwise samples are clipped.
+func (a *wavAudio) gain(db float64, limiterGain float64) {
+	factor := math.Pow(10, db/20)
+	threshold := math.Inf(1)
+	if limiterGain > 0 && factor > 1 {
+		threshold = math.MaxInt16 * (1 - limiterGain) / (factor - limiterGain)
+	}
+
+	for i, s := range a.Samples {
+		v := float64(s)
+		switch {
+		case v > threshold:
+			v = math.MaxInt16 - limiterGain*(math.MaxInt16-v)
+		case v < -threshold:
+			v = -(math.MaxInt16 - limiterGain*(math.MaxInt16+v))
+		default:
+			v *= factor
+		}
+		a.Samples[i] = int16(max(math.MinInt16, min(math.MaxInt16, math.Round(v))))
+	}
+}
+
+// Adds silence before and after the samples.
+func (a *wavAudio) pad(pre time.Duration, post time.Duration) {
+	res := make([]int16, a.sampleCount(pre), a.sampleCount(pre)+len(a.Samples)+a.sampleCount(post))
+	res = append(res, a.Samples...)
+	a.Samples = append(res, make([]int16, a.sampleCount(post))...)
+}
+
+// Converts the samples to the given sample rate with linear interpolation. When downsampling, the samples are
+// averaged first to filter out frequencies which can't be represented.
+func (a *wavAudio) resample(rate int) {
+	if rate == a.SampleRate || len(a.Samples) == 0 {
+		a.SampleRate = rate
+		return
+	}
+
+	samples := a.Samples
+	ratio := float64(a.SampleRate) / float64(rate)
+	if width := int(math.Ceil(ratio)); width > 1 {
+		samples = make([]int16, len(a.Samples))
+		for i := range samples {
+			var sum, count int
+			for j := i - width/2; j <= i+width/2; j++ {
+				if j >= 0 && j < len(a.Samples) {
+					sum += int(a.Samples[j])
+					count++
+				}
+			}
+			samples[i] = int16(sum / count)
+		}
+	}
+
+	res := make([]int16, int(float64(len(samples))/ratio))
+	for i := range res {
+		pos := float64(i) * ratio
+		j := int(pos)
+		next := min(j+1, len(samples)-1)
+		frac := pos - float64(j)
+		res[i] = int16(math.Round(float64(samples[j])*(1-frac) + float64(samples[next])*frac))
+	}
+	a.SampleRate = rate
+	a.Samples = res
+}
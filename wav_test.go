@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWavWriteRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.wav")
+	a := &wavAudio{SampleRate: 8000, Samples: []int16{0, 1, -1, 32767, -32768}}
+	if err := wavWrite(path, a); err != nil {
+		t.Fatal(err)
+	}
+	got, err := wavRead(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, a) {
+		t.Errorf("got %+v, want %+v", got, a)
+	}
+	if raw := a.rawBigEndian(); !reflect.DeepEqual(raw[:8], []byte{0, 0, 0, 1, 0xff, 0xff, 0x7f, 0xff}) {
+		t.Errorf("raw big endian %v", raw)
+	}
+}
+
+func TestWavReadChunkSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.wav")
+	a := &wavAudio{SampleRate: 8000, Samples: []int16{1, 2, 3, 4}}
+	if err := wavWrite(path, a); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		patch   func(d []byte) []byte
+		want    []int16
+		wantErr bool
+	}{
+		{"streaming data size", func(d []byte) []byte {
+			binary.LittleEndian.PutUint32(d[40:], 0xffffffff)
+			return d
+		}, []int16{1, 2, 3, 4}, false},
+		{"truncated data chunk", func(d []byte) []byte { return d[:len(d)-2] }, []int16{1, 2, 3}, false},
+		{"corrupt fmt chunk size", func(d []byte) []byte {
+			binary.LittleEndian.PutUint32(d[16:], 0xffffffff)
+			return d
+		}, nil, true},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(path, tt.patch(append([]byte{}, data...)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := wavRead(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got.Samples, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got.Samples, tt.want)
+		}
+	}
+}
+
+func TestWavGain(t *testing.T) {
+	tests := []struct {
+		name    string
+		db      float64
+		limiter float64
+		samples []int16
+		want    []int16
+	}{
+		{"plain", 6.0206, 0, []int16{0, 1000, -1000}, []int16{0, 2000, -2000}},
+		{"clipped", 6.0206, 0, []int16{20000, -20000}, []int16{32767, -32768}},
+		{"attenuated", -6.0206, 0.1, []int16{2000, -2000}, []int16{1000, -1000}},
+		// With a 2x gain and 0.1 limiter gain, the threshold is 32767*0.9/1.9 = 15522.
+		{"below threshold", 6.0206, 0.1, []int16{15000, -15000}, []int16{30000, -30000}},
+		{"limited", 6.0206, 0.1, []int16{20000, -20000, 32767, -32768}, []int16{31490, -31490, 32767, -32767}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &wavAudio{SampleRate: 8000, Samples: tt.samples}
+			a.gain(tt.db, tt.limiter)
+			if !reflect.DeepEqual(a.Samples, tt.want) {
+				t.Errorf("got %v, want %v", a.Samples, tt.want)
+			}
+		})
+	}
+}
+
+func TestWavTrimPad(t *testing.T) {
+	a := &wavAudio{SampleRate: 1000, Samples: []int16{1, 2, 3, 4, 5, 6}}
+	a.trim(2 * time.Millisecond)
+	if !reflect.DeepEqual(a.Samples, []int16{3, 4}) {
+		t.Errorf("trimmed to %v", a.Samples)
+	}
+	a.pad(time.Millisecond, 2*time.Millisecond)
+	if !reflect.DeepEqual(a.Samples, []int16{0, 3, 4, 0, 0}) {
+		t.Errorf("padded to %v", a.Samples)
+	}
+	a.trim(3 * time.Millisecond)
+	if len(a.Samples) != 0 {
+		t.Errorf("trimmed to %v", a.Samples)
+	}
+}
+
+func TestWavResample(t *testing.T) {
+	a := &wavAudio{SampleRate: 16000, Samples: []int16{100, 100, 100, 100, 100, 100, 100, 100}}
+	a.resample(8000)
+	if a.SampleRate != 8000 || !reflect.DeepEqual(a.Samples, []int16{100, 100, 100, 100}) {
+		t.Errorf("downsampled to %d Hz %v", a.SampleRate, a.Samples)
+	}
+
+	a = &wavAudio{SampleRate: 8000, Samples: []int16{0, 100}}
+	a.resample(16000)
+	if !reflect.DeepEqual(a.Samples, []int16{0, 50, 100, 100}) {
+		t.Errorf("upsampled to %v", a.Samples)
+	}
+}